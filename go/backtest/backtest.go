@@ -0,0 +1,57 @@
+// Copyright (C) 2018 Cranky Kernel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package backtest checks a proposed stop-loss or trailing-profit setting
+// against recent klines before it is confirmed, so a user is warned if the
+// setting would have already been triggered by the last N candles' range.
+package backtest
+
+import "gitlab.com/crankykernel/maker/go/exchange"
+
+// Result reports whether a proposed stop price or trailing percentage
+// would already have triggered against the given kline history, and if
+// so, at what candle.
+type Result struct {
+	WouldHaveTriggered bool
+	TriggerOpenTime    int64
+}
+
+// CheckStopLoss reports whether stopPrice would have been hit by the low
+// of any of the given klines, most recent last.
+func CheckStopLoss(klines []exchange.Kline, stopPrice float64) Result {
+	for _, k := range klines {
+		if k.Low <= stopPrice {
+			return Result{WouldHaveTriggered: true, TriggerOpenTime: k.OpenTime}
+		}
+	}
+	return Result{}
+}
+
+// CheckTrailingProfit reports whether a trailing-profit percentage would
+// have already triggered a sell: it tracks the running high across the
+// klines and flags the first candle whose low falls percent below it.
+func CheckTrailingProfit(klines []exchange.Kline, entryPrice, percent float64) Result {
+	high := entryPrice
+	for _, k := range klines {
+		if k.High > high {
+			high = k.High
+		}
+		trigger := high * (1 - percent/100)
+		if k.Low <= trigger {
+			return Result{WouldHaveTriggered: true, TriggerOpenTime: k.OpenTime}
+		}
+	}
+	return Result{}
+}
@@ -0,0 +1,157 @@
+// Copyright (C) 2018 Cranky Kernel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package binanceex
+
+import (
+	"github.com/crankykernel/binanceapi-go"
+	"gitlab.com/crankykernel/maker/go/exchange"
+)
+
+const ExchangeName = "binance"
+
+func init() {
+	exchange.Register(ExchangeName, func(config map[string]string) (exchange.Exchange, error) {
+		return NewAdapter(config["apiKey"], config["apiSecret"]), nil
+	})
+}
+
+// Adapter implements exchange.Exchange for Binance spot trading, wrapping
+// the existing BinanceRestClient and streaming types used throughout
+// binanceex.
+type Adapter struct {
+	client              *binanceapi.RestClient
+	userDataStream      *BinanceUserDataStream
+	exchangeInfoService *ExchangeInfoService
+}
+
+func NewAdapter(apiKey, apiSecret string) *Adapter {
+	return &Adapter{
+		client:              binanceapi.NewAuthenticatedRestClient(apiKey, apiSecret),
+		userDataStream:      NewBinanceUserDataStream(),
+		exchangeInfoService: NewExchangeInfoService(),
+	}
+}
+
+func (a *Adapter) Name() string {
+	return ExchangeName
+}
+
+func (a *Adapter) PlaceOrder(pair exchange.CurrencyPair, order exchange.Order) (exchange.Order, error) {
+	response, err := a.client.PostOrder(binanceapi.PostOrderParameters{
+		Symbol:      pair.String(),
+		Side:        string(order.Side),
+		Type:        string(order.Type),
+		Quantity:    order.Quantity,
+		Price:       order.Price,
+		StopPrice:   order.StopPrice,
+	})
+	if err != nil {
+		return exchange.Order{}, err
+	}
+	order.Exchange = ExchangeName
+	order.OrderId = response.OrderIdAsString()
+	order.Status = response.Status
+	return order, nil
+}
+
+func (a *Adapter) CancelOrder(pair exchange.CurrencyPair, orderId string) error {
+	_, err := a.client.CancelOrder(pair.String(), orderId)
+	return err
+}
+
+func (a *Adapter) GetTicker(pair exchange.CurrencyPair) (exchange.Ticker, error) {
+	ticker, err := a.client.GetTickerPrice(pair.String())
+	if err != nil {
+		return exchange.Ticker{}, err
+	}
+	return exchange.Ticker{
+		Symbol: pair.String(),
+		Last:   ticker.Price,
+	}, nil
+}
+
+func (a *Adapter) GetKlines(pair exchange.CurrencyPair, interval string, startTime, endTime int64, limit int) ([]exchange.Kline, error) {
+	klines, err := a.client.GetKlines(pair.String(), interval, startTime, endTime, limit)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]exchange.Kline, 0, len(klines))
+	for _, k := range klines {
+		out = append(out, exchange.Kline{
+			OpenTime:  k.OpenTime,
+			Open:      k.Open,
+			High:      k.High,
+			Low:       k.Low,
+			Close:     k.Close,
+			Volume:    k.Volume,
+			CloseTime: k.CloseTime,
+		})
+	}
+	return out, nil
+}
+
+func (a *Adapter) GetAccount() (exchange.Account, error) {
+	account, err := a.client.GetAccount()
+	if err != nil {
+		return exchange.Account{}, err
+	}
+	balances := make([]exchange.Balance, 0, len(account.Balances))
+	for _, b := range account.Balances {
+		balances = append(balances, exchange.Balance{
+			Asset:  b.Asset,
+			Free:   b.Free,
+			Locked: b.Locked,
+		})
+	}
+	return exchange.Account{Balances: balances}, nil
+}
+
+func (a *Adapter) ExchangeInfo() (map[string]exchange.TickSize, error) {
+	if err := a.exchangeInfoService.Update(); err != nil {
+		return nil, err
+	}
+	out := map[string]exchange.TickSize{}
+	for symbol, info := range a.exchangeInfoService.GetTickSizes() {
+		out[symbol] = exchange.TickSize{
+			PriceTickSize:    info.PriceTickSize,
+			QuantityTickSize: info.QuantityTickSize,
+			MinNotional:      info.MinNotional,
+		}
+	}
+	return out, nil
+}
+
+func (a *Adapter) UserDataStream() (<-chan exchange.Order, error) {
+	raw := a.userDataStream.Subscribe()
+	out := make(chan exchange.Order)
+	go func() {
+		for event := range raw {
+			if event.EventType != EventTypeExecutionReport {
+				continue
+			}
+			out <- exchange.Order{
+				Exchange:      ExchangeName,
+				Symbol:        event.Symbol,
+				OrderId:       event.OrderIdAsString(),
+				ClientOrderId: event.ClientOrderId,
+				Side:          exchange.OrderSide(event.Side),
+				Status:        event.CurrentOrderStatus,
+			}
+		}
+	}()
+	go a.userDataStream.Run()
+	return out, nil
+}
@@ -0,0 +1,299 @@
+// Copyright (C) 2018 Cranky Kernel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package futures
+
+import (
+	"gitlab.com/crankykernel/maker/go/exchange"
+	"strconv"
+	"sync"
+)
+
+// ExchangeName is the registry key for Binance USDⓈ-M perpetual futures,
+// kept distinct from binanceex.ExchangeName ("binance") since it is a
+// separate account, wallet and order book from Binance spot.
+const ExchangeName = "binance-futures"
+
+func init() {
+	exchange.Register(ExchangeName, func(config map[string]string) (exchange.Exchange, error) {
+		return NewAdapter(config["apiKey"], config["apiSecret"]), nil
+	})
+}
+
+// Adapter implements exchange.Exchange for Binance USDⓈ-M futures, plus
+// the additional leverage and margin-type controls futures trading needs
+// that spot exchanges have no equivalent for.
+type Adapter struct {
+	client         *RestClient
+	userDataStream *UserDataStream
+	runStreamOnce  sync.Once
+}
+
+func NewAdapter(apiKey, apiSecret string) *Adapter {
+	client := NewRestClient(apiKey, apiSecret)
+	return &Adapter{
+		client:         client,
+		userDataStream: NewUserDataStream(client),
+	}
+}
+
+func (a *Adapter) Name() string {
+	return ExchangeName
+}
+
+func (a *Adapter) PlaceOrder(pair exchange.CurrencyPair, order exchange.Order) (exchange.Order, error) {
+	response, err := a.client.PlaceOrder(PlaceOrderParameters{
+		Symbol:    pair.String(),
+		Side:      string(order.Side),
+		Type:      string(order.Type),
+		Quantity:  order.Quantity,
+		Price:     order.Price,
+		StopPrice: order.StopPrice,
+	})
+	if err != nil {
+		return exchange.Order{}, err
+	}
+	order.Exchange = ExchangeName
+	order.OrderId = strconv.FormatInt(response.OrderId, 10)
+	order.Status = response.Status
+	return order, nil
+}
+
+func (a *Adapter) CancelOrder(pair exchange.CurrencyPair, orderId string) error {
+	id, err := strconv.ParseInt(orderId, 10, 64)
+	if err != nil {
+		return err
+	}
+	return a.client.CancelOrder(pair.String(), id)
+}
+
+func (a *Adapter) GetTicker(pair exchange.CurrencyPair) (exchange.Ticker, error) {
+	markPrice, err := a.client.GetMarkPrice(pair.String())
+	if err != nil {
+		return exchange.Ticker{}, err
+	}
+	return exchange.Ticker{Symbol: pair.String(), Last: markPrice.MarkPrice}, nil
+}
+
+func (a *Adapter) GetKlines(pair exchange.CurrencyPair, interval string, startTime, endTime int64, limit int) ([]exchange.Kline, error) {
+	rows, err := a.client.GetKlines(pair.String(), interval, startTime, endTime, limit)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]exchange.Kline, 0, len(rows))
+	for _, row := range rows {
+		if len(row) < 7 {
+			continue
+		}
+		out = append(out, exchange.Kline{
+			OpenTime:  int64(row[0].(float64)),
+			Open:      toFloat(row[1]),
+			High:      toFloat(row[2]),
+			Low:       toFloat(row[3]),
+			Close:     toFloat(row[4]),
+			Volume:    toFloat(row[5]),
+			CloseTime: int64(row[6].(float64)),
+		})
+	}
+	return out, nil
+}
+
+func toFloat(v interface{}) float64 {
+	s, _ := v.(string)
+	f, _ := strconv.ParseFloat(s, 64)
+	return f
+}
+
+func (a *Adapter) GetAccount() (exchange.Account, error) {
+	balances, err := a.client.GetAccountBalance()
+	if err != nil {
+		return exchange.Account{}, err
+	}
+	account := exchange.Account{}
+	for _, b := range balances {
+		free, _ := strconv.ParseFloat(b.AvailableBalance, 64)
+		total, _ := strconv.ParseFloat(b.Balance, 64)
+		account.Balances = append(account.Balances, exchange.Balance{
+			Asset:  b.Asset,
+			Free:   free,
+			Locked: total - free,
+		})
+	}
+	return account, nil
+}
+
+func (a *Adapter) ExchangeInfo() (map[string]exchange.TickSize, error) {
+	info, err := a.client.GetExchangeInfo()
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]exchange.TickSize{}
+	for _, symbol := range info.Symbols {
+		tickSize := exchange.TickSize{}
+		for _, filter := range symbol.Filters {
+			switch filter.FilterType {
+			case "PRICE_FILTER":
+				tickSize.PriceTickSize, _ = strconv.ParseFloat(filter.TickSize, 64)
+			case "LOT_SIZE":
+				tickSize.QuantityTickSize, _ = strconv.ParseFloat(filter.StepSize, 64)
+			case "MIN_NOTIONAL":
+				tickSize.MinNotional, _ = strconv.ParseFloat(filter.MinNotional, 64)
+			}
+		}
+		out[symbol.Symbol] = tickSize
+	}
+	return out, nil
+}
+
+func (a *Adapter) UserDataStream() (<-chan exchange.Order, error) {
+	raw := a.userDataStream.Subscribe()
+	out := make(chan exchange.Order)
+	go func() {
+		for event := range raw {
+			if event.EventType != EventTypeOrderTradeUpdate {
+				continue
+			}
+			out <- exchange.Order{
+				Exchange: ExchangeName,
+				Symbol:   event.Symbol,
+				OrderId:  strconv.FormatInt(event.OrderId, 10),
+				Side:     exchange.OrderSide(event.Side),
+				Status:   event.OrderStatus,
+			}
+		}
+	}()
+	a.runStreamOnce.Do(func() { go a.userDataStream.Run() })
+	return out, nil
+}
+
+// AccountUpdates subscribes to ACCOUNT_UPDATE events on the futures user
+// data stream, handing back just the positions they carry. It is separate
+// from UserDataStream, which only forwards ORDER_TRADE_UPDATE since
+// exchange.Order has no room for position/margin fields. Whichever of the
+// two is called first starts the stream; it is started only once.
+func (a *Adapter) AccountUpdates() <-chan []AccountUpdatePosition {
+	raw := a.userDataStream.Subscribe()
+	out := make(chan []AccountUpdatePosition)
+	go func() {
+		for event := range raw {
+			if event.EventType != EventTypeAccountUpdate {
+				continue
+			}
+			out <- event.Positions
+		}
+	}()
+	a.runStreamOnce.Do(func() { go a.userDataStream.Run() })
+	return out
+}
+
+// TrackMarkPriceUntil runs a MarkPriceStream for the given symbols, calling
+// onUpdate for every price tick, until stop is closed. Run it in its own
+// goroutine; closing stop disconnects the underlying websocket rather than
+// leaking it for the life of the process.
+func (a *Adapter) TrackMarkPriceUntil(stop <-chan struct{}, symbols []string, onUpdate func(symbol string, markPrice float64)) {
+	NewMarkPriceStream(symbols).Run(stop, onUpdate)
+}
+
+// SetLeverage sets the leverage for a symbol. Futures-only; there is no
+// equivalent for spot exchanges so it is not part of exchange.Exchange.
+func (a *Adapter) SetLeverage(pair exchange.CurrencyPair, leverage int) error {
+	return a.client.SetLeverage(pair.String(), leverage)
+}
+
+// SetMarginType sets the margin type ("ISOLATED" or "CROSSED") for a
+// symbol. Futures-only.
+func (a *Adapter) SetMarginType(pair exchange.CurrencyPair, marginType string) error {
+	return a.client.SetMarginType(pair.String(), marginType)
+}
+
+// GetPositionRisk returns the open position, if any, along with its
+// liquidation price and margin mode for a symbol.
+func (a *Adapter) GetPositionRisk(pair exchange.CurrencyPair) ([]PositionRisk, error) {
+	return a.client.GetPositionRisk(pair.String())
+}
+
+// PlaceStopMarketOrder places a native STOP_MARKET conditional order,
+// which futures fills server-side once the mark price crosses stopPrice,
+// unlike the spot stop-loss emulation which watches price client-side.
+func (a *Adapter) PlaceStopMarketOrder(pair exchange.CurrencyPair, side exchange.OrderSide, stopPrice float64, closePosition bool) (exchange.Order, error) {
+	response, err := a.client.PlaceOrder(PlaceOrderParameters{
+		Symbol:        pair.String(),
+		Side:          string(side),
+		Type:          "STOP_MARKET",
+		StopPrice:     stopPrice,
+		ClosePosition: closePosition,
+	})
+	if err != nil {
+		return exchange.Order{}, err
+	}
+	return exchange.Order{
+		Exchange: ExchangeName,
+		Symbol:   pair.String(),
+		OrderId:  strconv.FormatInt(response.OrderId, 10),
+		Side:     side,
+		Type:     "STOP_MARKET",
+		Status:   response.Status,
+	}, nil
+}
+
+// PlaceCloseOrder places a reduce-only LIMIT or MARKET order against an
+// open position. ReduceOnly is used instead of inferring direction from
+// quantity so the order can only shrink the existing position and can
+// never flip it to the opposite side if the caller's view of the position
+// is stale.
+func (a *Adapter) PlaceCloseOrder(pair exchange.CurrencyPair, side exchange.OrderSide, orderType string, quantity, price float64) (exchange.Order, error) {
+	response, err := a.client.PlaceOrder(PlaceOrderParameters{
+		Symbol:     pair.String(),
+		Side:       string(side),
+		Type:       orderType,
+		Quantity:   quantity,
+		Price:      price,
+		ReduceOnly: true,
+	})
+	if err != nil {
+		return exchange.Order{}, err
+	}
+	return exchange.Order{
+		Exchange: ExchangeName,
+		Symbol:   pair.String(),
+		OrderId:  strconv.FormatInt(response.OrderId, 10),
+		Side:     side,
+		Type:     exchange.OrderType(orderType),
+		Status:   response.Status,
+	}, nil
+}
+
+// PlaceTrailingStopMarketOrder places a native TRAILING_STOP_MARKET
+// conditional order with the given callback rate (percent).
+func (a *Adapter) PlaceTrailingStopMarketOrder(pair exchange.CurrencyPair, side exchange.OrderSide, callbackRate float64, closePosition bool) (exchange.Order, error) {
+	response, err := a.client.PlaceOrder(PlaceOrderParameters{
+		Symbol:        pair.String(),
+		Side:          string(side),
+		Type:          "TRAILING_STOP_MARKET",
+		CallbackRate:  callbackRate,
+		ClosePosition: closePosition,
+	})
+	if err != nil {
+		return exchange.Order{}, err
+	}
+	return exchange.Order{
+		Exchange: ExchangeName,
+		Symbol:   pair.String(),
+		OrderId:  strconv.FormatInt(response.OrderId, 10),
+		Side:     side,
+		Type:     "TRAILING_STOP_MARKET",
+		Status:   response.Status,
+	}, nil
+}
@@ -0,0 +1,105 @@
+// Copyright (C) 2018 Cranky Kernel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package futures
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"gitlab.com/crankykernel/maker/go/log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MarkPriceStream subscribes to the combined mark price stream for a set
+// of symbols, used to drive liquidation-price and unrealized-PNL display
+// without polling GetMarkPrice.
+type MarkPriceStream struct {
+	symbols []string
+}
+
+func NewMarkPriceStream(symbols []string) *MarkPriceStream {
+	return &MarkPriceStream{symbols: symbols}
+}
+
+// Run connects and reconnects to the mark price stream, calling onUpdate
+// for every tick, until stop is closed. Closing stop unblocks a pending
+// read by forcing the connection closed, so a caller that is done with a
+// symbol (eg. its position closed) doesn't leak the goroutine or websocket
+// for the life of the process.
+func (s *MarkPriceStream) Run(stop <-chan struct{}, onUpdate func(symbol string, markPrice float64)) {
+	streams := make([]string, 0, len(s.symbols))
+	for _, symbol := range s.symbols {
+		streams = append(streams, strings.ToLower(symbol)+"@markPrice")
+	}
+	url := fmt.Sprintf("wss://fstream.binance.com/stream?streams=%s", strings.Join(streams, "/"))
+
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+		if err != nil {
+			log.WithError(err).Errorf("futures: failed to connect mark price stream")
+			time.Sleep(5 * time.Second)
+			continue
+		}
+
+		closed := make(chan struct{})
+		go func() {
+			select {
+			case <-stop:
+				conn.Close()
+			case <-closed:
+			}
+		}()
+
+		for {
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				log.WithError(err).Errorf("futures: mark price stream read error, reconnecting")
+				break
+			}
+			var envelope struct {
+				Data struct {
+					Symbol    string `json:"s"`
+					MarkPrice string `json:"p"`
+				} `json:"data"`
+			}
+			if err := json.Unmarshal(message, &envelope); err != nil {
+				continue
+			}
+			markPrice, err := strconv.ParseFloat(envelope.Data.MarkPrice, 64)
+			if err != nil {
+				continue
+			}
+			onUpdate(envelope.Data.Symbol, markPrice)
+		}
+
+		close(closed)
+		conn.Close()
+
+		select {
+		case <-stop:
+			return
+		default:
+		}
+	}
+}
@@ -0,0 +1,335 @@
+// Copyright (C) 2018 Cranky Kernel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package futures talks to Binance's USDⓈ-M perpetual futures API
+// (fapi.binance.com), mirroring the spot binanceapi-go client closely
+// enough that binanceex/futures.Adapter can be used the same way as the
+// spot binanceex.Adapter.
+package futures
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const baseUrl = "https://fapi.binance.com"
+
+// RestClient is a minimal client for the endpoints Maker needs from the
+// USDⓈ-M futures API: placing and cancelling orders, reading account and
+// position state, and exchange trading rules.
+type RestClient struct {
+	apiKey    string
+	apiSecret string
+	client    *http.Client
+}
+
+func NewRestClient(apiKey, apiSecret string) *RestClient {
+	return &RestClient{
+		apiKey:    apiKey,
+		apiSecret: apiSecret,
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (c *RestClient) sign(params url.Values) string {
+	mac := hmac.New(sha256.New, []byte(c.apiSecret))
+	mac.Write([]byte(params.Encode()))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (c *RestClient) signedRequest(method, path string, params url.Values) ([]byte, error) {
+	if params == nil {
+		params = url.Values{}
+	}
+	params.Set("timestamp", strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10))
+	params.Set("signature", c.sign(params))
+
+	request, err := http.NewRequest(method, baseUrl+path+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("X-MBX-APIKEY", c.apiKey)
+	response, err := c.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	var raw json.RawMessage
+	if err := json.NewDecoder(response.Body).Decode(&raw); err != nil {
+		return nil, err
+	}
+	if response.StatusCode >= 400 {
+		return nil, fmt.Errorf("futures: request to %s failed: %s", path, string(raw))
+	}
+	return raw, nil
+}
+
+type OrderResponse struct {
+	OrderId       int64  `json:"orderId"`
+	ClientOrderId string `json:"clientOrderId"`
+	Symbol        string `json:"symbol"`
+	Status        string `json:"status"`
+}
+
+// PlaceOrderParameters mirrors the fapi /fapi/v1/order request body,
+// including the conditional order types (STOP_MARKET, TRAILING_STOP_MARKET)
+// that are native to futures and have no spot equivalent.
+type PlaceOrderParameters struct {
+	Symbol           string
+	Side             string
+	PositionSide     string
+	Type             string
+	Quantity         float64
+	Price            float64
+	StopPrice        float64
+	CallbackRate     float64
+	ClosePosition    bool
+	ReduceOnly       bool
+}
+
+func (c *RestClient) PlaceOrder(p PlaceOrderParameters) (*OrderResponse, error) {
+	params := url.Values{}
+	params.Set("symbol", p.Symbol)
+	params.Set("side", p.Side)
+	params.Set("type", p.Type)
+	if p.PositionSide != "" {
+		params.Set("positionSide", p.PositionSide)
+	}
+	if p.Quantity > 0 {
+		params.Set("quantity", strconv.FormatFloat(p.Quantity, 'f', -1, 64))
+	}
+	if p.Price > 0 {
+		params.Set("price", strconv.FormatFloat(p.Price, 'f', -1, 64))
+	}
+	if p.StopPrice > 0 {
+		params.Set("stopPrice", strconv.FormatFloat(p.StopPrice, 'f', -1, 64))
+	}
+	if p.CallbackRate > 0 {
+		params.Set("callbackRate", strconv.FormatFloat(p.CallbackRate, 'f', -1, 64))
+	}
+	if p.ClosePosition {
+		params.Set("closePosition", "true")
+	}
+	if p.ReduceOnly {
+		params.Set("reduceOnly", "true")
+	}
+	raw, err := c.signedRequest("POST", "/fapi/v1/order", params)
+	if err != nil {
+		return nil, err
+	}
+	var response OrderResponse
+	if err := json.Unmarshal(raw, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+func (c *RestClient) CancelOrder(symbol string, orderId int64) error {
+	params := url.Values{}
+	params.Set("symbol", symbol)
+	params.Set("orderId", strconv.FormatInt(orderId, 10))
+	_, err := c.signedRequest("DELETE", "/fapi/v1/order", params)
+	return err
+}
+
+func (c *RestClient) SetLeverage(symbol string, leverage int) error {
+	params := url.Values{}
+	params.Set("symbol", symbol)
+	params.Set("leverage", strconv.Itoa(leverage))
+	_, err := c.signedRequest("POST", "/fapi/v1/leverage", params)
+	return err
+}
+
+func (c *RestClient) SetMarginType(symbol string, marginType string) error {
+	params := url.Values{}
+	params.Set("symbol", symbol)
+	params.Set("marginType", strings.ToUpper(marginType))
+	_, err := c.signedRequest("POST", "/fapi/v1/marginType", params)
+	return err
+}
+
+type PositionRisk struct {
+	Symbol           string `json:"symbol"`
+	PositionAmt      string `json:"positionAmt"`
+	EntryPrice       string `json:"entryPrice"`
+	LiquidationPrice string `json:"liquidationPrice"`
+	Leverage         string `json:"leverage"`
+	MarginType       string `json:"marginType"`
+	PositionSide     string `json:"positionSide"`
+}
+
+func (c *RestClient) GetPositionRisk(symbol string) ([]PositionRisk, error) {
+	params := url.Values{}
+	if symbol != "" {
+		params.Set("symbol", symbol)
+	}
+	raw, err := c.signedRequest("GET", "/fapi/v2/positionRisk", params)
+	if err != nil {
+		return nil, err
+	}
+	var positions []PositionRisk
+	if err := json.Unmarshal(raw, &positions); err != nil {
+		return nil, err
+	}
+	return positions, nil
+}
+
+type AccountBalance struct {
+	Asset            string `json:"asset"`
+	AvailableBalance string `json:"availableBalance"`
+	Balance          string `json:"balance"`
+}
+
+func (c *RestClient) GetAccountBalance() ([]AccountBalance, error) {
+	raw, err := c.signedRequest("GET", "/fapi/v2/balance", url.Values{})
+	if err != nil {
+		return nil, err
+	}
+	var balances []AccountBalance
+	if err := json.Unmarshal(raw, &balances); err != nil {
+		return nil, err
+	}
+	return balances, nil
+}
+
+type MarkPrice struct {
+	Symbol      string  `json:"symbol"`
+	MarkPrice   float64 `json:"markPrice,string"`
+	IndexPrice  float64 `json:"indexPrice,string"`
+}
+
+func (c *RestClient) GetMarkPrice(symbol string) (*MarkPrice, error) {
+	response, err := c.client.Get(fmt.Sprintf("%s/fapi/v1/premiumIndex?symbol=%s", baseUrl, symbol))
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	var markPrice MarkPrice
+	if err := json.NewDecoder(response.Body).Decode(&markPrice); err != nil {
+		return nil, err
+	}
+	return &markPrice, nil
+}
+
+type SymbolInfo struct {
+	Symbol  string `json:"symbol"`
+	Filters []struct {
+		FilterType string `json:"filterType"`
+		TickSize   string `json:"tickSize"`
+		StepSize   string `json:"stepSize"`
+		MinNotional string `json:"notional"`
+	} `json:"filters"`
+}
+
+type ExchangeInfoResponse struct {
+	Symbols []SymbolInfo `json:"symbols"`
+}
+
+func (c *RestClient) GetExchangeInfo() (*ExchangeInfoResponse, error) {
+	response, err := c.client.Get(baseUrl + "/fapi/v1/exchangeInfo")
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	var info ExchangeInfoResponse
+	if err := json.NewDecoder(response.Body).Decode(&info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// GetServerTime returns the futures API's current time in Unix
+// milliseconds, mirroring the spot binanceapi-go client's GetTime.
+func (c *RestClient) GetServerTime() (int64, error) {
+	response, err := c.client.Get(baseUrl + "/fapi/v1/time")
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	var body struct {
+		ServerTime int64 `json:"serverTime"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		return 0, err
+	}
+	return body.ServerTime, nil
+}
+
+func (c *RestClient) GetKlines(symbol, interval string, startTime, endTime int64, limit int) ([][]interface{}, error) {
+	url := fmt.Sprintf("%s/fapi/v1/klines?symbol=%s&interval=%s", baseUrl, symbol, interval)
+	if startTime > 0 {
+		url += fmt.Sprintf("&startTime=%d", startTime)
+	}
+	if endTime > 0 {
+		url += fmt.Sprintf("&endTime=%d", endTime)
+	}
+	if limit > 0 {
+		url += fmt.Sprintf("&limit=%d", limit)
+	}
+	response, err := c.client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	var rows [][]interface{}
+	if err := json.NewDecoder(response.Body).Decode(&rows); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// StartUserDataStream requests a listenKey for the futures user data
+// stream, the same pattern binanceex uses for spot.
+func (c *RestClient) StartUserDataStream() (string, error) {
+	request, err := http.NewRequest("POST", baseUrl+"/fapi/v1/listenKey", nil)
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("X-MBX-APIKEY", c.apiKey)
+	response, err := c.client.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	var body struct {
+		ListenKey string `json:"listenKey"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	return body.ListenKey, nil
+}
+
+func (c *RestClient) KeepAliveUserDataStream(listenKey string) error {
+	request, err := http.NewRequest("PUT", baseUrl+"/fapi/v1/listenKey?listenKey="+listenKey, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("X-MBX-APIKEY", c.apiKey)
+	response, err := c.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	return nil
+}
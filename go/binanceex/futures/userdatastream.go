@@ -0,0 +1,211 @@
+// Copyright (C) 2018 Cranky Kernel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package futures
+
+import (
+	"encoding/json"
+	"github.com/gorilla/websocket"
+	"gitlab.com/crankykernel/maker/go/log"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const userStreamUrl = "wss://fstream.binance.com/ws/"
+
+// EventType is the "e" field of a futures user data stream event.
+type EventType string
+
+const (
+	EventTypeAccountUpdate    EventType = "ACCOUNT_UPDATE"
+	EventTypeOrderTradeUpdate EventType = "ORDER_TRADE_UPDATE"
+)
+
+// UserDataEvent is a parsed futures user data stream event. Only the
+// fields Maker currently acts on are broken out; Raw retains the full
+// message for persistence, mirroring binanceex.BinanceUserDataStream.
+type UserDataEvent struct {
+	EventType EventType
+	EventTime int64
+	Raw       []byte
+
+	// Populated for ORDER_TRADE_UPDATE.
+	Symbol             string
+	ClientOrderId      string
+	Side               string
+	OrderType          string
+	OrderStatus        string
+	OrderId            int64
+
+	// Populated for ACCOUNT_UPDATE.
+	Positions []AccountUpdatePosition
+}
+
+type AccountUpdatePosition struct {
+	Symbol           string
+	PositionAmount   float64
+	EntryPrice       float64
+	MarginType       string
+	IsolatedWallet   float64
+	PositionSide     string
+}
+
+// UserDataStream maintains the authenticated futures user data websocket
+// and its listenKey keepalive, publishing parsed events to subscribers.
+type UserDataStream struct {
+	client       *RestClient
+	subscribers  []chan UserDataEvent
+	lock         sync.Mutex
+}
+
+func NewUserDataStream(client *RestClient) *UserDataStream {
+	return &UserDataStream{client: client}
+}
+
+func (s *UserDataStream) Subscribe() chan UserDataEvent {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	channel := make(chan UserDataEvent, 64)
+	s.subscribers = append(s.subscribers, channel)
+	return channel
+}
+
+func (s *UserDataStream) publish(event UserDataEvent) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, subscriber := range s.subscribers {
+		subscriber <- event
+	}
+}
+
+// Run connects to the futures user data stream and keeps it alive,
+// reconnecting with a new listenKey on any read error. It never returns,
+// matching the run-forever style of binanceex.BinanceUserDataStream.
+func (s *UserDataStream) Run() {
+	for {
+		listenKey, err := s.client.StartUserDataStream()
+		if err != nil {
+			log.WithError(err).Errorf("futures: failed to start user data stream")
+			time.Sleep(5 * time.Second)
+			continue
+		}
+
+		stopKeepAlive := make(chan struct{})
+		go s.keepAlive(listenKey, stopKeepAlive)
+
+		conn, _, err := websocket.DefaultDialer.Dial(userStreamUrl+listenKey, nil)
+		if err != nil {
+			log.WithError(err).Errorf("futures: failed to connect user data stream")
+			close(stopKeepAlive)
+			time.Sleep(5 * time.Second)
+			continue
+		}
+
+		for {
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				log.WithError(err).Errorf("futures: user data stream read error, reconnecting")
+				break
+			}
+			event, err := parseUserDataEvent(message)
+			if err != nil {
+				log.WithError(err).Errorf("futures: failed to parse user data event")
+				continue
+			}
+			s.publish(event)
+		}
+
+		conn.Close()
+		close(stopKeepAlive)
+	}
+}
+
+// keepAlive pings the listenKey every 30 minutes, as required by the
+// futures API to keep the stream from expiring after 60 minutes.
+func (s *UserDataStream) keepAlive(listenKey string, stop chan struct{}) {
+	ticker := time.NewTicker(30 * time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.client.KeepAliveUserDataStream(listenKey); err != nil {
+				log.WithError(err).Errorf("futures: failed to keep alive user data stream")
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+func parseUserDataEvent(raw []byte) (UserDataEvent, error) {
+	var envelope struct {
+		EventType string `json:"e"`
+		EventTime int64  `json:"E"`
+		Order     struct {
+			Symbol        string `json:"s"`
+			ClientOrderId string `json:"c"`
+			Side          string `json:"S"`
+			OrderType     string `json:"o"`
+			OrderStatus   string `json:"X"`
+			OrderId       int64  `json:"i"`
+		} `json:"o"`
+		Account struct {
+			Positions []struct {
+				Symbol         string `json:"s"`
+				PositionAmount string `json:"pa"`
+				EntryPrice     string `json:"ep"`
+				MarginType     string `json:"mt"`
+				IsolatedWallet string `json:"iw"`
+				PositionSide   string `json:"ps"`
+			} `json:"P"`
+		} `json:"a"`
+	}
+	if err := json.Unmarshal(raw, &envelope); err != nil {
+		return UserDataEvent{}, err
+	}
+
+	event := UserDataEvent{
+		EventType: EventType(envelope.EventType),
+		EventTime: envelope.EventTime,
+		Raw:       raw,
+	}
+
+	switch event.EventType {
+	case EventTypeOrderTradeUpdate:
+		event.Symbol = envelope.Order.Symbol
+		event.ClientOrderId = envelope.Order.ClientOrderId
+		event.Side = envelope.Order.Side
+		event.OrderType = envelope.Order.OrderType
+		event.OrderStatus = envelope.Order.OrderStatus
+		event.OrderId = envelope.Order.OrderId
+	case EventTypeAccountUpdate:
+		for _, p := range envelope.Account.Positions {
+			positionAmount, _ := strconv.ParseFloat(p.PositionAmount, 64)
+			entryPrice, _ := strconv.ParseFloat(p.EntryPrice, 64)
+			isolatedWallet, _ := strconv.ParseFloat(p.IsolatedWallet, 64)
+			event.Positions = append(event.Positions, AccountUpdatePosition{
+				Symbol:         p.Symbol,
+				PositionAmount: positionAmount,
+				EntryPrice:     entryPrice,
+				MarginType:     p.MarginType,
+				IsolatedWallet: isolatedWallet,
+				PositionSide:   p.PositionSide,
+			})
+		}
+	}
+
+	return event, nil
+}
@@ -0,0 +1,180 @@
+// Copyright (C) 2018 Cranky Kernel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package binanceex
+
+import (
+	"fmt"
+	"gitlab.com/crankykernel/maker/go/log"
+	"time"
+)
+
+// StreamEvent is the minimal shape StreamSupervisor needs from a stream's
+// events to detect gaps: an event time it can check for monotonicity.
+type StreamEvent struct {
+	EventTime int64
+	Raw       interface{}
+}
+
+// Session is a reconnectable source of StreamEvents, implemented by a thin
+// wrapper around BinanceUserDataStream or a symbol's XTradeStreamManager
+// stream. Connect dials and returns a channel that is closed when the
+// underlying connection drops.
+type Session interface {
+	// Connect dials the stream and returns a channel of events. The
+	// channel is closed when the connection is lost.
+	Connect() (<-chan StreamEvent, error)
+
+	// KeepAlive is called every 30 minutes to refresh the session's
+	// listenKey. A no-op implementation is fine for streams, like market
+	// data, that don't use a listenKey.
+	KeepAlive() error
+
+	// Resync is called after a reconnect, before events are forwarded to
+	// subscribers, to re-fetch open orders and account balances so that
+	// any fills that happened while disconnected aren't missed.
+	Resync() error
+}
+
+// Disconnectable is implemented by a Session that can be told to drop its
+// current connection on demand. It is only used by the --chaos-ws dev
+// flag to exercise reconnection; production sessions may opt out simply
+// by not implementing it.
+type Disconnectable interface {
+	ForceDisconnect()
+}
+
+// StreamSupervisor wraps a Session with bounded exponential-backoff
+// reconnection, listenKey keepalive, gap detection, and a resync step
+// after every reconnect, so that BinanceUserDataStream and
+// XTradeStreamManager streams recover from a dropped connection without
+// losing fills.
+type StreamSupervisor struct {
+	Name string
+
+	session Session
+	events  chan StreamEvent
+	notice  func(name string, message string)
+
+	minBackoff time.Duration
+	maxBackoff time.Duration
+}
+
+// NewStreamSupervisor creates a supervisor for the given session. notice,
+// if non-nil, is called on reconnect and resync events so operators can
+// see them via ClientNoticeService.
+func NewStreamSupervisor(name string, session Session, notice func(name, message string)) *StreamSupervisor {
+	return &StreamSupervisor{
+		Name:       name,
+		session:    session,
+		events:     make(chan StreamEvent, 256),
+		notice:     notice,
+		minBackoff: 1 * time.Second,
+		maxBackoff: 60 * time.Second,
+	}
+}
+
+// Events returns the channel of events forwarded from the underlying
+// session across however many reconnects it takes to keep it alive.
+func (s *StreamSupervisor) Events() <-chan StreamEvent {
+	return s.events
+}
+
+// Run connects and re-connects to the session forever, applying bounded
+// exponential backoff between attempts. It should be run in its own
+// goroutine and never returns.
+func (s *StreamSupervisor) Run() {
+	backoff := s.minBackoff
+	var lastEventTime int64
+	connectedBefore := false
+
+	go s.keepAliveLoop()
+
+	for {
+		raw, err := s.session.Connect()
+		if err != nil {
+			s.warnf("failed to connect: %v; retrying in %s", err, backoff)
+			time.Sleep(backoff)
+			backoff = nextBackoff(backoff, s.maxBackoff)
+			continue
+		}
+		backoff = s.minBackoff
+
+		if connectedBefore {
+			// This is a reconnect, not the initial connection: resync
+			// before forwarding new events so a fill that happened while
+			// disconnected isn't missed.
+			if err := s.session.Resync(); err != nil {
+				s.warnf("resync after reconnect failed: %v", err)
+			} else {
+				s.infof("resynced after reconnect")
+			}
+		}
+		connectedBefore = true
+
+		for event := range raw {
+			if lastEventTime != 0 && event.EventTime < lastEventTime {
+				s.warnf("event time went backwards (%d < %d); possible gap or clock skew",
+					event.EventTime, lastEventTime)
+			}
+			lastEventTime = event.EventTime
+			s.events <- event
+		}
+
+		s.warnf("stream disconnected; reconnecting")
+	}
+}
+
+// Chaos forces the current connection to drop, if the underlying Session
+// supports it, for use by the --chaos-ws dev flag. It is a no-op for
+// sessions that don't implement Disconnectable.
+func (s *StreamSupervisor) Chaos() {
+	if d, ok := s.session.(Disconnectable); ok {
+		s.infof("chaos-ws: forcing disconnect")
+		d.ForceDisconnect()
+	}
+}
+
+func (s *StreamSupervisor) keepAliveLoop() {
+	ticker := time.NewTicker(30 * time.Minute)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := s.session.KeepAlive(); err != nil {
+			s.warnf("keepalive failed: %v", err)
+		}
+	}
+}
+
+func (s *StreamSupervisor) warnf(format string, args ...interface{}) {
+	log.WithField("stream", s.Name).Warnf(format, args...)
+	if s.notice != nil {
+		s.notice(s.Name, fmt.Sprintf(format, args...))
+	}
+}
+
+func (s *StreamSupervisor) infof(format string, args ...interface{}) {
+	log.WithField("stream", s.Name).Infof(format, args...)
+	if s.notice != nil {
+		s.notice(s.Name, fmt.Sprintf(format, args...))
+	}
+}
+
+func nextBackoff(current, max time.Duration) time.Duration {
+	next := current * 2
+	if next > max {
+		return max
+	}
+	return next
+}
@@ -0,0 +1,147 @@
+// Copyright (C) 2018 Cranky Kernel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package binanceex
+
+import (
+	"github.com/gorilla/websocket"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flappyStreamServer is a fake Binance-style stream server that sends a
+// handful of events on every connection and then closes it, so a test can
+// exercise StreamSupervisor's reconnect logic without needing a real
+// exchange.
+type flappyStreamServer struct {
+	server        *httptest.Server
+	eventsPerConn int
+	connections   int32
+	upgrader      websocket.Upgrader
+}
+
+func newFlappyStreamServer(eventsPerConn int) *flappyStreamServer {
+	s := &flappyStreamServer{eventsPerConn: eventsPerConn}
+	s.server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+func (s *flappyStreamServer) handle(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	connNumber := atomic.AddInt32(&s.connections, 1)
+	base := int64(connNumber-1) * int64(s.eventsPerConn)
+	for i := 0; i < s.eventsPerConn; i++ {
+		eventTime := base + int64(i) + 1
+		message := []byte(`{"eventTime":` + strconv.FormatInt(eventTime, 10) + `}`)
+		if err := conn.WriteMessage(websocket.TextMessage, message); err != nil {
+			return
+		}
+	}
+	// Drop the connection mid-stream to force the supervisor to reconnect.
+}
+
+func (s *flappyStreamServer) wsURL() string {
+	u, _ := url.Parse(s.server.URL)
+	u.Scheme = "ws"
+	return u.String()
+}
+
+func (s *flappyStreamServer) Close() {
+	s.server.Close()
+}
+
+// testSession dials the flappy server and decodes its messages into
+// StreamEvents. Resync and KeepAlive calls are counted; the test asserts on
+// the resync count.
+type testSession struct {
+	url          string
+	resyncCount  int32
+	keepAliveCount int32
+}
+
+func (s *testSession) Connect() (<-chan StreamEvent, error) {
+	conn, _, err := websocket.DefaultDialer.Dial(s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	events := make(chan StreamEvent)
+	go func() {
+		defer close(events)
+		defer conn.Close()
+		for {
+			var payload struct {
+				EventTime int64 `json:"eventTime"`
+			}
+			if err := conn.ReadJSON(&payload); err != nil {
+				return
+			}
+			events <- StreamEvent{EventTime: payload.EventTime}
+		}
+	}()
+	return events, nil
+}
+
+func (s *testSession) KeepAlive() error {
+	atomic.AddInt32(&s.keepAliveCount, 1)
+	return nil
+}
+
+func (s *testSession) Resync() error {
+	atomic.AddInt32(&s.resyncCount, 1)
+	return nil
+}
+
+func TestStreamSupervisorReconnectsAcrossDrops(t *testing.T) {
+	server := newFlappyStreamServer(5)
+	defer server.Close()
+
+	session := &testSession{url: server.wsURL()}
+	supervisor := NewStreamSupervisor("test", session, nil)
+	supervisor.minBackoff = time.Millisecond
+	supervisor.maxBackoff = 10 * time.Millisecond
+
+	go supervisor.Run()
+
+	var lastEventTime int64
+	received := 0
+	timeout := time.After(5 * time.Second)
+	for received < 20 {
+		select {
+		case event := <-supervisor.Events():
+			if event.EventTime <= lastEventTime {
+				t.Fatalf("event time did not increase monotonically across reconnects: %d <= %d",
+					event.EventTime, lastEventTime)
+			}
+			lastEventTime = event.EventTime
+			received++
+		case <-timeout:
+			t.Fatalf("timed out waiting for events, received %d", received)
+		}
+	}
+
+	if atomic.LoadInt32(&session.resyncCount) == 0 {
+		t.Errorf("expected at least one resync after a reconnect")
+	}
+}
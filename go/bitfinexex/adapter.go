@@ -0,0 +1,214 @@
+// Copyright (C) 2018 Cranky Kernel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package bitfinexex implements the exchange.Exchange interface against the
+// Bitfinex v2 REST API.
+package bitfinexex
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"gitlab.com/crankykernel/maker/go/exchange"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const ExchangeName = "bitfinex"
+
+const baseUrl = "https://api.bitfinex.com"
+
+func init() {
+	exchange.Register(ExchangeName, func(config map[string]string) (exchange.Exchange, error) {
+		return NewAdapter(config["apiKey"], config["apiSecret"]), nil
+	})
+}
+
+// Adapter implements exchange.Exchange against Bitfinex's v2 API.
+type Adapter struct {
+	apiKey    string
+	apiSecret string
+	client    *http.Client
+}
+
+func NewAdapter(apiKey, apiSecret string) *Adapter {
+	return &Adapter{
+		apiKey:    apiKey,
+		apiSecret: apiSecret,
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (a *Adapter) Name() string {
+	return ExchangeName
+}
+
+func bitfinexSymbol(pair exchange.CurrencyPair) string {
+	return "t" + strings.ToUpper(pair.Base) + strings.ToUpper(pair.Quote)
+}
+
+func (a *Adapter) signedPost(path string, body map[string]interface{}) ([]byte, error) {
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	nonce := strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10)
+	payload := "/api" + path + nonce + string(encoded)
+	mac := hmac.New(sha512.New384, []byte(a.apiSecret))
+	mac.Write([]byte(payload))
+	signature := hex.EncodeToString(mac.Sum(nil))
+
+	request, err := http.NewRequest("POST", baseUrl+path, strings.NewReader(string(encoded)))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("bfx-nonce", nonce)
+	request.Header.Set("bfx-apikey", a.apiKey)
+	request.Header.Set("bfx-signature", signature)
+	response, err := a.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	var raw json.RawMessage
+	if err := json.NewDecoder(response.Body).Decode(&raw); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+func (a *Adapter) PlaceOrder(pair exchange.CurrencyPair, order exchange.Order) (exchange.Order, error) {
+	amount := order.Quantity
+	if order.Side == exchange.OrderSideSell {
+		amount = -amount
+	}
+	orderType := "EXCHANGE LIMIT"
+	if order.Type == exchange.OrderTypeMarket {
+		orderType = "EXCHANGE MARKET"
+	}
+	body := map[string]interface{}{
+		"type":   orderType,
+		"symbol": bitfinexSymbol(pair),
+		"amount": strconv.FormatFloat(amount, 'f', -1, 64),
+		"price":  strconv.FormatFloat(order.Price, 'f', -1, 64),
+	}
+	raw, err := a.signedPost("/v2/auth/w/order/submit", body)
+	if err != nil {
+		return exchange.Order{}, err
+	}
+	var response []interface{}
+	if err := json.Unmarshal(raw, &response); err != nil {
+		return exchange.Order{}, err
+	}
+	if len(response) < 7 || response[6] != "SUCCESS" {
+		return exchange.Order{}, fmt.Errorf("bitfinexex: place order failed: %s", string(raw))
+	}
+	order.Exchange = ExchangeName
+	return order, nil
+}
+
+func (a *Adapter) CancelOrder(pair exchange.CurrencyPair, orderId string) error {
+	id, err := strconv.ParseInt(orderId, 10, 64)
+	if err != nil {
+		return err
+	}
+	_, err = a.signedPost("/v2/auth/w/order/cancel", map[string]interface{}{"id": id})
+	return err
+}
+
+func (a *Adapter) GetTicker(pair exchange.CurrencyPair) (exchange.Ticker, error) {
+	response, err := a.client.Get(fmt.Sprintf("%s/v2/ticker/%s", baseUrl, bitfinexSymbol(pair)))
+	if err != nil {
+		return exchange.Ticker{}, err
+	}
+	defer response.Body.Close()
+	var fields []float64
+	if err := json.NewDecoder(response.Body).Decode(&fields); err != nil {
+		return exchange.Ticker{}, err
+	}
+	if len(fields) < 7 {
+		return exchange.Ticker{}, fmt.Errorf("bitfinexex: unexpected ticker response")
+	}
+	return exchange.Ticker{Symbol: pair.String(), Bid: fields[0], Ask: fields[2], Last: fields[6]}, nil
+}
+
+func (a *Adapter) GetKlines(pair exchange.CurrencyPair, interval string, startTime, endTime int64, limit int) ([]exchange.Kline, error) {
+	if limit <= 0 || limit > 10000 {
+		limit = 100
+	}
+	url := fmt.Sprintf("%s/v2/candles/trade:%s:%s/hist?limit=%d&start=%d&end=%d",
+		baseUrl, interval, bitfinexSymbol(pair), limit, startTime, endTime)
+	response, err := a.client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	var rows [][]float64
+	if err := json.NewDecoder(response.Body).Decode(&rows); err != nil {
+		return nil, err
+	}
+	out := make([]exchange.Kline, 0, len(rows))
+	for _, row := range rows {
+		if len(row) < 6 {
+			continue
+		}
+		out = append(out, exchange.Kline{
+			OpenTime: int64(row[0]),
+			Open:     row[1],
+			Close:    row[2],
+			High:     row[3],
+			Low:      row[4],
+			Volume:   row[5],
+		})
+	}
+	return out, nil
+}
+
+func (a *Adapter) GetAccount() (exchange.Account, error) {
+	raw, err := a.signedPost("/v2/auth/r/wallets", nil)
+	if err != nil {
+		return exchange.Account{}, err
+	}
+	var wallets [][]interface{}
+	if err := json.Unmarshal(raw, &wallets); err != nil {
+		return exchange.Account{}, err
+	}
+	account := exchange.Account{}
+	for _, w := range wallets {
+		if len(w) < 3 {
+			continue
+		}
+		currency, _ := w[1].(string)
+		balance, _ := w[2].(float64)
+		account.Balances = append(account.Balances, exchange.Balance{Asset: currency, Free: balance})
+	}
+	return account, nil
+}
+
+func (a *Adapter) ExchangeInfo() (map[string]exchange.TickSize, error) {
+	// Bitfinex does not publish per-symbol tick sizes; it displays prices at
+	// a fixed 5 significant digits, so callers should treat the tick size as
+	// advisory only.
+	return map[string]exchange.TickSize{}, nil
+}
+
+func (a *Adapter) UserDataStream() (<-chan exchange.Order, error) {
+	return nil, exchange.ErrNotSupported
+}
@@ -0,0 +1,134 @@
+// Copyright (C) 2018 Cranky Kernel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package exchange defines the exchange-agnostic types Maker uses to talk to
+// a cryptocurrency exchange, implemented by adapters for Binance, Huobi,
+// OKEX and Bitfinex. Trading logic in tradeservice does not use these
+// types; it still trades against Binance through binanceex.
+package exchange
+
+import "errors"
+
+// ErrNotSupported is returned by an Exchange implementation for operations
+// that the underlying exchange API does not support.
+var ErrNotSupported = errors.New("exchange: operation not supported")
+
+// OrderSide is the side of an order, buy or sell.
+type OrderSide string
+
+const (
+	OrderSideBuy  OrderSide = "BUY"
+	OrderSideSell OrderSide = "SELL"
+)
+
+// OrderType is the type of an order.
+type OrderType string
+
+const (
+	OrderTypeLimit      OrderType = "LIMIT"
+	OrderTypeMarket     OrderType = "MARKET"
+	OrderTypeStopLoss   OrderType = "STOP_LOSS"
+	OrderTypeStopLimit  OrderType = "STOP_LOSS_LIMIT"
+	OrderTypeTakeProfit OrderType = "TAKE_PROFIT"
+)
+
+// CurrencyPair identifies a market on an exchange in base/quote form, eg.
+// BTC/USDT.
+type CurrencyPair struct {
+	Base  string
+	Quote string
+}
+
+func (p CurrencyPair) String() string {
+	return p.Base + p.Quote
+}
+
+// TickSize describes the price and quantity precision an exchange enforces
+// for a given symbol.
+type TickSize struct {
+	PriceTickSize    float64
+	QuantityTickSize float64
+	MinNotional      float64
+}
+
+// Ticker is a snapshot of the best bid/ask/last price for a symbol.
+type Ticker struct {
+	Symbol string
+	Bid    float64
+	Ask    float64
+	Last   float64
+}
+
+// Kline is a single OHLCV candle.
+type Kline struct {
+	OpenTime  int64
+	Open      float64
+	High      float64
+	Low       float64
+	Close     float64
+	Volume    float64
+	CloseTime int64
+}
+
+// Order is an exchange-agnostic representation of an order, whether newly
+// placed, open, or filled.
+type Order struct {
+	Exchange        string
+	Symbol          string
+	OrderId         string
+	ClientOrderId   string
+	Side            OrderSide
+	Type            OrderType
+	Price           float64
+	StopPrice       float64
+	Quantity        float64
+	ExecutedQty     float64
+	Status          string
+}
+
+// Balance is the free/locked balance of a single asset in an account.
+type Balance struct {
+	Asset  string
+	Free   float64
+	Locked float64
+}
+
+// Account is a snapshot of the balances held on an exchange.
+type Account struct {
+	Balances []Balance
+}
+
+// Exchange is implemented by every exchange adapter Maker supports, and is
+// what the server's exchange registry holds. tradeservice.TradeService
+// does not trade through it; it still drives binanceex directly.
+type Exchange interface {
+	// Name returns the registry key for this exchange, eg. "binance".
+	Name() string
+
+	PlaceOrder(pair CurrencyPair, order Order) (Order, error)
+	CancelOrder(pair CurrencyPair, orderId string) error
+	GetTicker(pair CurrencyPair) (Ticker, error)
+	GetKlines(pair CurrencyPair, interval string, startTime, endTime int64, limit int) ([]Kline, error)
+	GetAccount() (Account, error)
+
+	// ExchangeInfo returns the tick size and other trading rules for every
+	// symbol the exchange lists.
+	ExchangeInfo() (map[string]TickSize, error)
+
+	// UserDataStream starts (or attaches to) the authenticated user data
+	// stream for this exchange and returns a channel of order updates as
+	// exchange.Order values, the same shape regardless of exchange.
+	UserDataStream() (<-chan Order, error)
+}
@@ -0,0 +1,62 @@
+// Copyright (C) 2018 Cranky Kernel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package exchange
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Factory builds an Exchange adapter from the credentials and any other
+// per-exchange configuration stored for it in maker.yaml.
+type Factory func(config map[string]string) (Exchange, error)
+
+var registry = struct {
+	sync.RWMutex
+	factories map[string]Factory
+}{
+	factories: map[string]Factory{},
+}
+
+// Register makes an exchange adapter available by name. It is intended to
+// be called from the init() function of each exchange adapter package.
+func Register(name string, factory Factory) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.factories[name] = factory
+}
+
+// Names returns the names of every registered exchange adapter.
+func Names() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+	names := make([]string, 0, len(registry.factories))
+	for name := range registry.factories {
+		names = append(names, name)
+	}
+	return names
+}
+
+// New builds the named exchange adapter with the given configuration.
+func New(name string, config map[string]string) (Exchange, error) {
+	registry.RLock()
+	factory, ok := registry.factories[name]
+	registry.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("exchange: no such exchange %q", name)
+	}
+	return factory(config)
+}
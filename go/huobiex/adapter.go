@@ -0,0 +1,301 @@
+// Copyright (C) 2018 Cranky Kernel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package huobiex implements the exchange.Exchange interface against the
+// Huobi Global REST and websocket APIs.
+package huobiex
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"gitlab.com/crankykernel/maker/go/exchange"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const ExchangeName = "huobi"
+
+const baseUrl = "https://api.huobi.pro"
+
+func init() {
+	exchange.Register(ExchangeName, func(config map[string]string) (exchange.Exchange, error) {
+		return NewAdapter(config["apiKey"], config["apiSecret"], config["accountId"]), nil
+	})
+}
+
+// Adapter implements exchange.Exchange against Huobi's REST API.
+type Adapter struct {
+	apiKey    string
+	apiSecret string
+	accountId string
+	client    *http.Client
+}
+
+func NewAdapter(apiKey, apiSecret, accountId string) *Adapter {
+	return &Adapter{
+		apiKey:    apiKey,
+		apiSecret: apiSecret,
+		accountId: accountId,
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (a *Adapter) Name() string {
+	return ExchangeName
+}
+
+// sign implements Huobi's "signature version 2" request signing scheme:
+// the request method, host, path and sorted query parameters are HMAC-SHA256
+// signed with the API secret and base64 encoded.
+func (a *Adapter) sign(method, path string, params url.Values) string {
+	params.Set("AccessKeyId", a.apiKey)
+	params.Set("SignatureMethod", "HmacSHA256")
+	params.Set("SignatureVersion", "2")
+	params.Set("Timestamp", time.Now().UTC().Format("2006-01-02T15:04:05"))
+
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%s", url.QueryEscape(k), url.QueryEscape(params.Get(k))))
+	}
+	payload := strings.Join([]string{method, "api.huobi.pro", path, strings.Join(parts, "&")}, "\n")
+
+	mac := hmac.New(sha256.New, []byte(a.apiSecret))
+	mac.Write([]byte(payload))
+	signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	params.Set("Signature", signature)
+	return params.Encode()
+}
+
+func huobiSymbol(pair exchange.CurrencyPair) string {
+	return strings.ToLower(pair.Base + pair.Quote)
+}
+
+func (a *Adapter) PlaceOrder(pair exchange.CurrencyPair, order exchange.Order) (exchange.Order, error) {
+	if a.accountId == "" {
+		return exchange.Order{}, fmt.Errorf("huobiex: accountId not configured")
+	}
+	body := map[string]interface{}{
+		"account-id": a.accountId,
+		"symbol":     huobiSymbol(pair),
+		"type":       strings.ToLower(string(order.Side)) + "-" + strings.ToLower(string(order.Type)),
+		"amount":     strconv.FormatFloat(order.Quantity, 'f', -1, 64),
+		"price":      strconv.FormatFloat(order.Price, 'f', -1, 64),
+	}
+	var response struct {
+		Status string `json:"status"`
+		Data   string `json:"data"`
+	}
+	if err := a.postJson("/v1/order/orders/place", body, &response); err != nil {
+		return exchange.Order{}, err
+	}
+	if response.Status != "ok" {
+		return exchange.Order{}, fmt.Errorf("huobiex: place order failed: %s", response.Status)
+	}
+	order.Exchange = ExchangeName
+	order.OrderId = response.Data
+	return order, nil
+}
+
+func (a *Adapter) CancelOrder(pair exchange.CurrencyPair, orderId string) error {
+	var response struct {
+		Status string `json:"status"`
+	}
+	path := fmt.Sprintf("/v1/order/orders/%s/submitcancel", orderId)
+	if err := a.postJson(path, map[string]interface{}{}, &response); err != nil {
+		return err
+	}
+	if response.Status != "ok" {
+		return fmt.Errorf("huobiex: cancel order failed: %s", response.Status)
+	}
+	return nil
+}
+
+func (a *Adapter) GetTicker(pair exchange.CurrencyPair) (exchange.Ticker, error) {
+	var response struct {
+		Tick struct {
+			Bid   []float64 `json:"bid"`
+			Ask   []float64 `json:"ask"`
+			Close float64   `json:"close"`
+		} `json:"tick"`
+	}
+	url := fmt.Sprintf("%s/market/detail/merged?symbol=%s", baseUrl, huobiSymbol(pair))
+	if err := a.getJson(url, &response); err != nil {
+		return exchange.Ticker{}, err
+	}
+	ticker := exchange.Ticker{Symbol: pair.String(), Last: response.Tick.Close}
+	if len(response.Tick.Bid) > 0 {
+		ticker.Bid = response.Tick.Bid[0]
+	}
+	if len(response.Tick.Ask) > 0 {
+		ticker.Ask = response.Tick.Ask[0]
+	}
+	return ticker, nil
+}
+
+func (a *Adapter) GetKlines(pair exchange.CurrencyPair, interval string, startTime, endTime int64, limit int) ([]exchange.Kline, error) {
+	var response struct {
+		Data []struct {
+			Id     int64   `json:"id"`
+			Open   float64 `json:"open"`
+			Close  float64 `json:"close"`
+			High   float64 `json:"high"`
+			Low    float64 `json:"low"`
+			Amount float64 `json:"amount"`
+		} `json:"data"`
+	}
+	if limit <= 0 || limit > 2000 {
+		limit = 150
+	}
+	url := fmt.Sprintf("%s/market/history/kline?symbol=%s&period=%s&size=%d",
+		baseUrl, huobiSymbol(pair), interval, limit)
+	if err := a.getJson(url, &response); err != nil {
+		return nil, err
+	}
+	out := make([]exchange.Kline, 0, len(response.Data))
+	for _, k := range response.Data {
+		out = append(out, exchange.Kline{
+			OpenTime: k.Id * 1000,
+			Open:     k.Open,
+			High:     k.High,
+			Low:      k.Low,
+			Close:    k.Close,
+			Volume:   k.Amount,
+		})
+	}
+	return out, nil
+}
+
+func (a *Adapter) GetAccount() (exchange.Account, error) {
+	if a.accountId == "" {
+		return exchange.Account{}, fmt.Errorf("huobiex: accountId not configured")
+	}
+	var response struct {
+		Data struct {
+			List []struct {
+				Currency string `json:"currency"`
+				Type     string `json:"type"`
+				Balance  string `json:"balance"`
+			} `json:"list"`
+		} `json:"data"`
+	}
+	path := fmt.Sprintf("/v1/account/accounts/%s/balance", a.accountId)
+	if err := a.getSignedJson(path, url.Values{}, &response); err != nil {
+		return exchange.Account{}, err
+	}
+	balances := map[string]*exchange.Balance{}
+	for _, entry := range response.Data.List {
+		b, ok := balances[entry.Currency]
+		if !ok {
+			b = &exchange.Balance{Asset: entry.Currency}
+			balances[entry.Currency] = b
+		}
+		amount, _ := strconv.ParseFloat(entry.Balance, 64)
+		if entry.Type == "trade" {
+			b.Free = amount
+		} else {
+			b.Locked = amount
+		}
+	}
+	account := exchange.Account{}
+	for _, b := range balances {
+		account.Balances = append(account.Balances, *b)
+	}
+	return account, nil
+}
+
+func (a *Adapter) ExchangeInfo() (map[string]exchange.TickSize, error) {
+	var response struct {
+		Data []struct {
+			Symbol           string `json:"symbol"`
+			PricePrecision   int    `json:"price-precision"`
+			AmountPrecision  int    `json:"amount-precision"`
+			MinOrderValue    float64 `json:"min-order-value"`
+		} `json:"data"`
+	}
+	if err := a.getJson(baseUrl+"/v1/common/symbols", &response); err != nil {
+		return nil, err
+	}
+	out := map[string]exchange.TickSize{}
+	for _, s := range response.Data {
+		out[strings.ToUpper(s.Symbol)] = exchange.TickSize{
+			PriceTickSize:    1 / pow10(s.PricePrecision),
+			QuantityTickSize: 1 / pow10(s.AmountPrecision),
+			MinNotional:      s.MinOrderValue,
+		}
+	}
+	return out, nil
+}
+
+func (a *Adapter) UserDataStream() (<-chan exchange.Order, error) {
+	return nil, exchange.ErrNotSupported
+}
+
+func pow10(n int) float64 {
+	v := 1.0
+	for i := 0; i < n; i++ {
+		v *= 10
+	}
+	return v
+}
+
+func (a *Adapter) getJson(url string, out interface{}) error {
+	response, err := a.client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	return json.NewDecoder(response.Body).Decode(out)
+}
+
+func (a *Adapter) getSignedJson(path string, params url.Values, out interface{}) error {
+	query := a.sign("GET", path, params)
+	response, err := a.client.Get(baseUrl + path + "?" + query)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	return json.NewDecoder(response.Body).Decode(out)
+}
+
+func (a *Adapter) postJson(path string, body map[string]interface{}, out interface{}) error {
+	query := a.sign("POST", path, url.Values{})
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("POST", baseUrl+path+"?"+query, strings.NewReader(string(encoded)))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := a.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	return json.NewDecoder(response.Body).Decode(out)
+}
@@ -0,0 +1,149 @@
+// Copyright (C) 2018 Cranky Kernel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package klinecache caches exchange klines in sqlite, keyed by
+// symbol+interval+openTime, so the UI can render candle charts for open
+// trades without re-fetching history that was already downloaded and so
+// tradeservice can backtest proposed stop-loss/trailing-profit settings
+// against recent history already on disk.
+package klinecache
+
+import (
+	"database/sql"
+	"gitlab.com/crankykernel/maker/go/exchange"
+	"path"
+)
+
+var db *sql.DB
+
+// Open opens (creating if needed) the kline cache database in the given
+// data directory and ensures its schema exists.
+func Open(dataDirectory string) error {
+	var err error
+	db, err = sql.Open("sqlite3", path.Join(dataDirectory, "klines.sqlite"))
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`
+		create table if not exists kline (
+			exchange text not null,
+			symbol text not null,
+			interval text not null,
+			open_time integer not null,
+			close_time integer not null,
+			open real not null,
+			high real not null,
+			low real not null,
+			close real not null,
+			volume real not null,
+			primary key (exchange, symbol, interval, open_time)
+		)
+	`)
+	return err
+}
+
+// Save inserts or replaces the given klines in the cache.
+func Save(exchangeName, symbol, interval string, klines []exchange.Kline) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	statement, err := tx.Prepare(`
+		insert or replace into kline
+			(exchange, symbol, interval, open_time, close_time, open, high, low, close, volume)
+		values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer statement.Close()
+	for _, k := range klines {
+		if _, err := statement.Exec(exchangeName, symbol, interval, k.OpenTime, k.CloseTime,
+			k.Open, k.High, k.Low, k.Close, k.Volume); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Query returns cached klines for a symbol/interval within [startTime,
+// endTime), ordered by openTime, starting after the given cursor (an
+// openTime, 0 to start from the beginning) and capped at limit rows.
+func Query(exchangeName, symbol, interval string, startTime, endTime, cursor int64, limit int) ([]exchange.Kline, error) {
+	if limit <= 0 || limit > 1000 {
+		limit = 1000
+	}
+	rows, err := db.Query(`
+		select open_time, close_time, open, high, low, close, volume
+		from kline
+		where exchange = ? and symbol = ? and interval = ?
+			and open_time > ?
+			and (? = 0 or open_time >= ?)
+			and (? = 0 or open_time < ?)
+		order by open_time asc
+		limit ?
+	`, exchangeName, symbol, interval, cursor, startTime, startTime, endTime, endTime, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var klines []exchange.Kline
+	for rows.Next() {
+		var k exchange.Kline
+		if err := rows.Scan(&k.OpenTime, &k.CloseTime, &k.Open, &k.High, &k.Low, &k.Close, &k.Volume); err != nil {
+			return nil, err
+		}
+		klines = append(klines, k)
+	}
+	return klines, rows.Err()
+}
+
+// QueryRecent returns the most recent `limit` cached klines for a
+// symbol/interval, oldest first, so a caller like the backtest package can
+// check a proposed stop-loss or trailing-profit setting against the tail
+// of price history already on disk without specifying a time range.
+func QueryRecent(exchangeName, symbol, interval string, limit int) ([]exchange.Kline, error) {
+	if limit <= 0 || limit > 1000 {
+		limit = 1000
+	}
+	rows, err := db.Query(`
+		select open_time, close_time, open, high, low, close, volume
+		from (
+			select open_time, close_time, open, high, low, close, volume
+			from kline
+			where exchange = ? and symbol = ? and interval = ?
+			order by open_time desc
+			limit ?
+		)
+		order by open_time asc
+	`, exchangeName, symbol, interval, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var klines []exchange.Kline
+	for rows.Next() {
+		var k exchange.Kline
+		if err := rows.Scan(&k.OpenTime, &k.CloseTime, &k.Open, &k.High, &k.Low, &k.Close, &k.Volume); err != nil {
+			return nil, err
+		}
+		klines = append(klines, k)
+	}
+	return klines, rows.Err()
+}
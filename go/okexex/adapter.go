@@ -0,0 +1,256 @@
+// Copyright (C) 2018 Cranky Kernel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package okexex implements the exchange.Exchange interface against the
+// OKEX v3 REST API.
+package okexex
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"gitlab.com/crankykernel/maker/go/exchange"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const ExchangeName = "okex"
+
+const baseUrl = "https://www.okex.com"
+
+func init() {
+	exchange.Register(ExchangeName, func(config map[string]string) (exchange.Exchange, error) {
+		return NewAdapter(config["apiKey"], config["apiSecret"], config["passphrase"]), nil
+	})
+}
+
+// Adapter implements exchange.Exchange against OKEX's spot trading API.
+type Adapter struct {
+	apiKey     string
+	apiSecret  string
+	passphrase string
+	client     *http.Client
+}
+
+func NewAdapter(apiKey, apiSecret, passphrase string) *Adapter {
+	return &Adapter{
+		apiKey:     apiKey,
+		apiSecret:  apiSecret,
+		passphrase: passphrase,
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (a *Adapter) Name() string {
+	return ExchangeName
+}
+
+func okexSymbol(pair exchange.CurrencyPair) string {
+	return strings.ToUpper(pair.Base) + "-" + strings.ToUpper(pair.Quote)
+}
+
+func (a *Adapter) sign(timestamp, method, path, body string) string {
+	payload := timestamp + method + path + body
+	mac := hmac.New(sha256.New, []byte(a.apiSecret))
+	mac.Write([]byte(payload))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (a *Adapter) doSigned(method, path string, body []byte) ([]byte, error) {
+	timestamp := time.Now().UTC().Format("2006-01-02T15:04:05.000Z")
+	request, err := http.NewRequest(method, baseUrl+path, strings.NewReader(string(body)))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("OK-ACCESS-KEY", a.apiKey)
+	request.Header.Set("OK-ACCESS-SIGN", a.sign(timestamp, method, path, string(body)))
+	request.Header.Set("OK-ACCESS-TIMESTAMP", timestamp)
+	request.Header.Set("OK-ACCESS-PASSPHRASE", a.passphrase)
+	response, err := a.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	buf := make([]byte, 0)
+	decoder := json.NewDecoder(response.Body)
+	var raw json.RawMessage
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, err
+	}
+	return append(buf, raw...), nil
+}
+
+func (a *Adapter) PlaceOrder(pair exchange.CurrencyPair, order exchange.Order) (exchange.Order, error) {
+	body, _ := json.Marshal(map[string]interface{}{
+		"instrument_id": okexSymbol(pair),
+		"side":          strings.ToLower(string(order.Side)),
+		"type":          strings.ToLower(string(order.Type)),
+		"size":          strconv.FormatFloat(order.Quantity, 'f', -1, 64),
+		"price":         strconv.FormatFloat(order.Price, 'f', -1, 64),
+	})
+	raw, err := a.doSigned("POST", "/api/spot/v3/orders", body)
+	if err != nil {
+		return exchange.Order{}, err
+	}
+	var response struct {
+		OrderId string `json:"order_id"`
+		Result  bool   `json:"result"`
+	}
+	if err := json.Unmarshal(raw, &response); err != nil {
+		return exchange.Order{}, err
+	}
+	if !response.Result {
+		return exchange.Order{}, fmt.Errorf("okexex: place order failed: %s", string(raw))
+	}
+	order.Exchange = ExchangeName
+	order.OrderId = response.OrderId
+	return order, nil
+}
+
+func (a *Adapter) CancelOrder(pair exchange.CurrencyPair, orderId string) error {
+	path := fmt.Sprintf("/api/spot/v3/cancel_orders/%s", orderId)
+	body, _ := json.Marshal(map[string]interface{}{"instrument_id": okexSymbol(pair)})
+	_, err := a.doSigned("POST", path, body)
+	return err
+}
+
+func (a *Adapter) GetTicker(pair exchange.CurrencyPair) (exchange.Ticker, error) {
+	response, err := a.client.Get(fmt.Sprintf("%s/api/spot/v3/instruments/%s/ticker", baseUrl, okexSymbol(pair)))
+	if err != nil {
+		return exchange.Ticker{}, err
+	}
+	defer response.Body.Close()
+	var ticker struct {
+		Last     string `json:"last"`
+		BestBid  string `json:"best_bid"`
+		BestAsk  string `json:"best_ask"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&ticker); err != nil {
+		return exchange.Ticker{}, err
+	}
+	last, _ := strconv.ParseFloat(ticker.Last, 64)
+	bid, _ := strconv.ParseFloat(ticker.BestBid, 64)
+	ask, _ := strconv.ParseFloat(ticker.BestAsk, 64)
+	return exchange.Ticker{Symbol: pair.String(), Last: last, Bid: bid, Ask: ask}, nil
+}
+
+func (a *Adapter) GetKlines(pair exchange.CurrencyPair, interval string, startTime, endTime int64, limit int) ([]exchange.Kline, error) {
+	granularity, err := strconv.Atoi(interval)
+	if err != nil {
+		return nil, fmt.Errorf("okexex: interval must be given in seconds: %v", err)
+	}
+	url := fmt.Sprintf("%s/api/spot/v3/instruments/%s/candles?granularity=%d",
+		baseUrl, okexSymbol(pair), granularity)
+	response, err := a.client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	var rows [][]interface{}
+	if err := json.NewDecoder(response.Body).Decode(&rows); err != nil {
+		return nil, err
+	}
+	out := make([]exchange.Kline, 0, len(rows))
+	for _, row := range rows {
+		if len(row) < 6 {
+			continue
+		}
+		t, _ := time.Parse(time.RFC3339, fmt.Sprint(row[0]))
+		out = append(out, exchange.Kline{
+			OpenTime: t.UnixNano() / int64(time.Millisecond),
+			Open:     toFloat(row[1]),
+			High:     toFloat(row[2]),
+			Low:      toFloat(row[3]),
+			Close:    toFloat(row[4]),
+			Volume:   toFloat(row[5]),
+		})
+	}
+	return out, nil
+}
+
+func toFloat(v interface{}) float64 {
+	switch t := v.(type) {
+	case float64:
+		return t
+	case string:
+		f, _ := strconv.ParseFloat(t, 64)
+		return f
+	}
+	return 0
+}
+
+func (a *Adapter) GetAccount() (exchange.Account, error) {
+	raw, err := a.doSigned("GET", "/api/spot/v3/accounts", nil)
+	if err != nil {
+		return exchange.Account{}, err
+	}
+	var entries []struct {
+		Currency  string `json:"currency"`
+		Available string `json:"available"`
+		Hold      string `json:"hold"`
+	}
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		return exchange.Account{}, err
+	}
+	account := exchange.Account{}
+	for _, e := range entries {
+		free, _ := strconv.ParseFloat(e.Available, 64)
+		locked, _ := strconv.ParseFloat(e.Hold, 64)
+		account.Balances = append(account.Balances, exchange.Balance{
+			Asset:  e.Currency,
+			Free:   free,
+			Locked: locked,
+		})
+	}
+	return account, nil
+}
+
+func (a *Adapter) ExchangeInfo() (map[string]exchange.TickSize, error) {
+	response, err := a.client.Get(baseUrl + "/api/spot/v3/instruments")
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	var instruments []struct {
+		InstrumentId string `json:"instrument_id"`
+		TickSize     string `json:"tick_size"`
+		SizeIncrement string `json:"size_increment"`
+		MinSize      string `json:"min_size"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&instruments); err != nil {
+		return nil, err
+	}
+	out := map[string]exchange.TickSize{}
+	for _, i := range instruments {
+		price, _ := strconv.ParseFloat(i.TickSize, 64)
+		size, _ := strconv.ParseFloat(i.SizeIncrement, 64)
+		minSize, _ := strconv.ParseFloat(i.MinSize, 64)
+		out[strings.Replace(i.InstrumentId, "-", "", 1)] = exchange.TickSize{
+			PriceTickSize:    price,
+			QuantityTickSize: size,
+			MinNotional:      minSize,
+		}
+	}
+	return out, nil
+}
+
+func (a *Adapter) UserDataStream() (<-chan exchange.Order, error) {
+	return nil, exchange.ErrNotSupported
+}
@@ -0,0 +1,618 @@
+// Copyright (C) 2018 Cranky Kernel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"gitlab.com/crankykernel/maker/go/binanceex/futures"
+	"gitlab.com/crankykernel/maker/go/exchange"
+	"gitlab.com/crankykernel/maker/go/log"
+	"gitlab.com/crankykernel/maker/go/tradeservice"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// futuresLiquidationWarnThreshold is how close, as a fraction of the
+// liquidation price, a position's mark price must come before
+// startFuturesLiquidationMonitor raises a warning.
+const futuresLiquidationWarnThreshold = 0.01
+
+// startFuturesLiquidationMonitor watches ACCOUNT_UPDATE events for open
+// futures positions, refreshing the liquidation price of every open
+// position an update reports via GetPositionRisk and tracking each
+// position's mark price via TrackMarkPriceUntil, so
+// notice fires if the mark price comes within futuresLiquidationWarnThreshold
+// of liquidation. The per-symbol mark price stream is torn down once its
+// position closes (PositionAmount==0) rather than left running. It never
+// returns; run it in its own goroutine.
+func startFuturesLiquidationMonitor(adapter *futures.Adapter, notice func(level ClientNoticeLevel, message string)) {
+	var mu sync.Mutex
+	tracked := map[string]func(){}
+	liquidationPrice := map[string]float64{}
+
+	for positions := range adapter.AccountUpdates() {
+		for _, p := range positions {
+			if p.PositionAmount == 0 {
+				mu.Lock()
+				delete(liquidationPrice, p.Symbol)
+				if stop, ok := tracked[p.Symbol]; ok {
+					stop()
+					delete(tracked, p.Symbol)
+				}
+				mu.Unlock()
+				continue
+			}
+
+			risk, err := adapter.GetPositionRisk(exchange.CurrencyPair{Base: p.Symbol})
+			if err != nil {
+				log.WithError(err).Errorf("Failed to refresh liquidation price for %s", p.Symbol)
+				continue
+			}
+			for _, r := range risk {
+				liqPrice, _ := strconv.ParseFloat(r.LiquidationPrice, 64)
+				mu.Lock()
+				liquidationPrice[r.Symbol] = liqPrice
+				mu.Unlock()
+			}
+
+			mu.Lock()
+			_, alreadyTracked := tracked[p.Symbol]
+			mu.Unlock()
+			if alreadyTracked {
+				continue
+			}
+
+			symbol := p.Symbol
+			stop := make(chan struct{})
+			mu.Lock()
+			tracked[symbol] = func() { close(stop) }
+			mu.Unlock()
+			go adapter.TrackMarkPriceUntil(stop, []string{symbol}, func(_ string, markPrice float64) {
+				mu.Lock()
+				liqPrice := liquidationPrice[symbol]
+				mu.Unlock()
+				if liqPrice == 0 {
+					return
+				}
+				if math.Abs(markPrice-liqPrice)/liqPrice <= futuresLiquidationWarnThreshold {
+					notice(ClientNoticeLevelWarning, fmt.Sprintf(
+						"%s mark price %.8f is within %.0f%% of its liquidation price %.8f",
+						symbol, markPrice, futuresLiquidationWarnThreshold*100, liqPrice))
+				}
+			})
+		}
+	}
+}
+
+// futuresAdapter looks up the registered binance-futures adapter, if any
+// credentials have been configured for it.
+func futuresAdapter() (*futures.Adapter, bool) {
+	adapter, ok := getExchangeAdapter(futures.ExchangeName)
+	if !ok {
+		return nil, false
+	}
+	futuresAdapter, ok := adapter.(*futures.Adapter)
+	return futuresAdapter, ok
+}
+
+// closingPositionSide returns the position side that an order with the
+// given side would close: a SELL closes a LONG, a BUY closes a SHORT. Used
+// to pick the right row out of GetPositionRisk in hedge mode, where it can
+// return both a LONG and a SHORT row for the same symbol.
+func closingPositionSide(orderSide exchange.OrderSide) string {
+	if orderSide == exchange.OrderSideBuy {
+		return "SHORT"
+	}
+	return "LONG"
+}
+
+// recordFuturesPosition fetches the live position for pair and attaches
+// its leverage, margin type, liquidation price, and position side to the
+// trade identified by tradeId, so the UI reflects what the exchange
+// actually holds after a conditional order is placed, not just the order
+// itself. wantSide picks which row to use when hedge mode's GetPositionRisk
+// returns both a LONG and a SHORT row for the same symbol; in one-way mode
+// there is only ever one matching row and it is used regardless of
+// PositionSide ("BOTH").
+func recordFuturesPosition(tradeService *tradeservice.TradeService, tradeId string, adapter *futures.Adapter, pair exchange.CurrencyPair, wantSide string) error {
+	positions, err := adapter.GetPositionRisk(pair)
+	if err != nil {
+		return err
+	}
+	for _, p := range positions {
+		positionAmount, _ := strconv.ParseFloat(p.PositionAmt, 64)
+		if positionAmount == 0 {
+			continue
+		}
+		if p.PositionSide != "" && p.PositionSide != "BOTH" && p.PositionSide != wantSide {
+			continue
+		}
+		leverage, _ := strconv.Atoi(p.Leverage)
+		liquidationPrice, _ := strconv.ParseFloat(p.LiquidationPrice, 64)
+		return tradeService.SetFuturesPosition(tradeId, tradeservice.FuturesPosition{
+			Leverage:         leverage,
+			MarginType:       p.MarginType,
+			LiquidationPrice: liquidationPrice,
+			PositionSide:     wantSide,
+		})
+	}
+	return nil
+}
+
+// futuresStopLossHandler places a native STOP_MARKET order for the trade's
+// symbol, which the futures matching engine executes server-side once the
+// mark price crosses stopPrice, rather than emulating it client-side the
+// way the spot stop-loss handler does. It then associates the order with
+// tradeId by recording the resulting position's leverage, margin type,
+// liquidation price, and position side on the trade.
+func futuresStopLossHandler(tradeService *tradeservice.TradeService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tradeId := mux.Vars(r)["tradeId"]
+
+		adapter, ok := futuresAdapter()
+		if !ok {
+			WriteJsonError(w, http.StatusNotFound, "binance-futures is not configured")
+			return
+		}
+
+		var request struct {
+			Symbol     string  `json:"symbol"`
+			Side       string  `json:"side"`
+			StopPrice  float64 `json:"stopPrice"`
+			MarginType string  `json:"marginType"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			log.WithError(err).Errorf("Failed to decode stop-loss request")
+			WriteJsonError(w, http.StatusBadRequest, "failed to decode request body")
+			return
+		}
+
+		pair := exchange.CurrencyPair{Base: request.Symbol}
+
+		if request.MarginType != "" {
+			if err := adapter.SetMarginType(pair, request.MarginType); err != nil {
+				log.WithError(err).Errorf("Failed to set margin type for %s", request.Symbol)
+				WriteJsonError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+		}
+
+		order, err := adapter.PlaceStopMarketOrder(pair, exchange.OrderSide(request.Side), request.StopPrice, true)
+		if err != nil {
+			log.WithError(err).Errorf("Failed to place futures stop-loss order")
+			WriteJsonError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		if err := recordFuturesPosition(tradeService, tradeId, adapter, pair, closingPositionSide(exchange.OrderSide(request.Side))); err != nil {
+			log.WithError(err).Errorf("Failed to record futures position for trade %s", tradeId)
+		}
+
+		WriteJsonResponse(w, http.StatusOK, order)
+	}
+}
+
+// futuresTrailingProfitHandler places a native TRAILING_STOP_MARKET order,
+// using callbackRate as the trailing percentage, and records the resulting
+// position against tradeId the same way futuresStopLossHandler does.
+func futuresTrailingProfitHandler(tradeService *tradeservice.TradeService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tradeId := mux.Vars(r)["tradeId"]
+
+		adapter, ok := futuresAdapter()
+		if !ok {
+			WriteJsonError(w, http.StatusNotFound, "binance-futures is not configured")
+			return
+		}
+
+		var request struct {
+			Symbol       string  `json:"symbol"`
+			Side         string  `json:"side"`
+			CallbackRate float64 `json:"callbackRate"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			log.WithError(err).Errorf("Failed to decode trailing-profit request")
+			WriteJsonError(w, http.StatusBadRequest, "failed to decode request body")
+			return
+		}
+
+		pair := exchange.CurrencyPair{Base: request.Symbol}
+		order, err := adapter.PlaceTrailingStopMarketOrder(pair, exchange.OrderSide(request.Side), request.CallbackRate, true)
+		if err != nil {
+			log.WithError(err).Errorf("Failed to place futures trailing-profit order")
+			WriteJsonError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		if err := recordFuturesPosition(tradeService, tradeId, adapter, pair, closingPositionSide(exchange.OrderSide(request.Side))); err != nil {
+			log.WithError(err).Errorf("Failed to record futures position for trade %s", tradeId)
+		}
+
+		WriteJsonResponse(w, http.StatusOK, order)
+	}
+}
+
+// futuresLeveragePreferenceHandler sets the per-symbol default leverage
+// used when opening new futures trades. It applies the leverage to the
+// exchange immediately (so it takes effect for a position opened right
+// now) and persists it to the "futuresLeverage" section of maker.yaml,
+// which futuresBuyHandler reads via loadFuturesLeverageDefault for any
+// later open request that doesn't specify its own leverage.
+func futuresLeveragePreferenceHandler(configFilename string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		symbol := mux.Vars(r)["symbol"]
+
+		var request struct {
+			Leverage int `json:"leverage"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			log.WithError(err).Errorf("Failed to decode leverage preference")
+			WriteJsonError(w, http.StatusBadRequest, "failed to decode request body")
+			return
+		}
+
+		adapter, ok := futuresAdapter()
+		if !ok {
+			WriteJsonError(w, http.StatusNotFound, "binance-futures is not configured")
+			return
+		}
+		if err := adapter.SetLeverage(exchange.CurrencyPair{Base: symbol}, request.Leverage); err != nil {
+			log.WithError(err).Errorf("Failed to set leverage for %s", symbol)
+			WriteJsonError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		if err := saveFuturesLeverageDefault(configFilename, symbol, request.Leverage); err != nil {
+			log.WithError(err).Errorf("Failed to save default leverage for %s", symbol)
+			WriteJsonError(w, http.StatusInternalServerError, "failed to save leverage preference")
+			return
+		}
+
+		WriteJsonResponse(w, http.StatusOK, map[string]interface{}{})
+	}
+}
+
+// loadFuturesLeverageDefault returns the default leverage previously saved
+// for symbol by saveFuturesLeverageDefault, or 0 if none has been set (the
+// caller falls back to whatever leverage is already configured on the
+// exchange for that symbol).
+func loadFuturesLeverageDefault(configFilename, symbol string) int {
+	config := struct {
+		FuturesLeverage map[string]int `yaml:"futuresLeverage"`
+	}{}
+	buf, err := ioutil.ReadFile(configFilename)
+	if err != nil {
+		return 0
+	}
+	if err := yaml.Unmarshal(buf, &config); err != nil {
+		log.WithError(err).Errorf("Failed to parse %s", configFilename)
+		return 0
+	}
+	return config.FuturesLeverage[symbol]
+}
+
+// saveFuturesLeverageDefault merges symbol's default leverage into the
+// "futuresLeverage" section of maker.yaml, leaving the file's other
+// sections as they were, following the same read-modify-write pattern
+// SaveExchangeConfigHandler uses for exchange credentials.
+func saveFuturesLeverageDefault(configFilename, symbol string, leverage int) error {
+	config := struct {
+		FuturesLeverage map[string]int `yaml:"futuresLeverage"`
+	}{}
+	full := map[string]interface{}{}
+	if buf, err := ioutil.ReadFile(configFilename); err == nil {
+		if err := yaml.Unmarshal(buf, &config); err != nil {
+			log.WithError(err).Errorf("Failed to parse %s", configFilename)
+		}
+		yaml.Unmarshal(buf, &full)
+	}
+	if config.FuturesLeverage == nil {
+		config.FuturesLeverage = map[string]int{}
+	}
+	config.FuturesLeverage[symbol] = leverage
+	full["futuresLeverage"] = config.FuturesLeverage
+
+	out, err := yaml.Marshal(full)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(configFilename, out, 0600)
+}
+
+// futuresBuyHandler opens a futures position with a MARKET or LIMIT order
+// (request.Type, default MARKET), natively against binance-futures, rather
+// than falling through to the generic /api/{exchange}/buy route, which
+// would place it as a Binance spot order instead. If request.Leverage is
+// unset it applies the symbol's saved default from
+// futuresLeveragePreferenceHandler, if any.
+func futuresBuyHandler(tradeService *tradeservice.TradeService, configFilename string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		adapter, ok := futuresAdapter()
+		if !ok {
+			WriteJsonError(w, http.StatusNotFound, "binance-futures is not configured")
+			return
+		}
+
+		var request struct {
+			Symbol     string  `json:"symbol"`
+			Side       string  `json:"side"`
+			Type       string  `json:"type"`
+			Quantity   float64 `json:"quantity"`
+			Price      float64 `json:"price"`
+			Leverage   int     `json:"leverage"`
+			MarginType string  `json:"marginType"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			log.WithError(err).Errorf("Failed to decode futures buy request")
+			WriteJsonError(w, http.StatusBadRequest, "failed to decode request body")
+			return
+		}
+
+		pair := exchange.CurrencyPair{Base: request.Symbol}
+
+		leverage := request.Leverage
+		if leverage == 0 {
+			leverage = loadFuturesLeverageDefault(configFilename, request.Symbol)
+		}
+		if leverage != 0 {
+			if err := adapter.SetLeverage(pair, leverage); err != nil {
+				log.WithError(err).Errorf("Failed to set leverage for %s", request.Symbol)
+				WriteJsonError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+		}
+		if request.MarginType != "" {
+			if err := adapter.SetMarginType(pair, request.MarginType); err != nil {
+				log.WithError(err).Errorf("Failed to set margin type for %s", request.Symbol)
+				WriteJsonError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+		}
+
+		orderType := request.Type
+		if orderType == "" {
+			orderType = string(exchange.OrderTypeMarket)
+		}
+		order, err := adapter.PlaceOrder(pair, exchange.Order{
+			Side:     exchange.OrderSide(request.Side),
+			Type:     exchange.OrderType(orderType),
+			Quantity: request.Quantity,
+			Price:    request.Price,
+		})
+		if err != nil {
+			log.WithError(err).Errorf("Failed to place futures buy order for %s", request.Symbol)
+			WriteJsonError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		tradeId, err := tradeService.OpenFuturesTrade(pair.String(), order)
+		if err != nil {
+			log.WithError(err).Errorf("Failed to open futures trade record for %s", request.Symbol)
+			WriteJsonError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		openedSide := "LONG"
+		if exchange.OrderSide(request.Side) == exchange.OrderSideSell {
+			openedSide = "SHORT"
+		}
+		if err := recordFuturesPosition(tradeService, tradeId, adapter, pair, openedSide); err != nil {
+			log.WithError(err).Errorf("Failed to record futures position for trade %s", tradeId)
+		}
+
+		WriteJsonResponse(w, http.StatusOK, order)
+	}
+}
+
+// futuresCancelBuyHandler cancels a still-open (unfilled) futures entry
+// order, mirroring deleteBuyHandler's role for spot.
+func futuresCancelBuyHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		adapter, ok := futuresAdapter()
+		if !ok {
+			WriteJsonError(w, http.StatusNotFound, "binance-futures is not configured")
+			return
+		}
+
+		var request struct {
+			Symbol  string `json:"symbol"`
+			OrderId string `json:"orderId"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			log.WithError(err).Errorf("Failed to decode futures cancel-buy request")
+			WriteJsonError(w, http.StatusBadRequest, "failed to decode request body")
+			return
+		}
+
+		if err := adapter.CancelOrder(exchange.CurrencyPair{Base: request.Symbol}, request.OrderId); err != nil {
+			log.WithError(err).Errorf("Failed to cancel futures order %s", request.OrderId)
+			WriteJsonError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		WriteJsonResponse(w, http.StatusOK, map[string]interface{}{})
+	}
+}
+
+// futuresSellHandler cancels a still-open futures exit order, mirroring
+// DeleteSellHandler's role for spot.
+func futuresSellHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		adapter, ok := futuresAdapter()
+		if !ok {
+			WriteJsonError(w, http.StatusNotFound, "binance-futures is not configured")
+			return
+		}
+
+		var request struct {
+			Symbol  string `json:"symbol"`
+			OrderId string `json:"orderId"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			log.WithError(err).Errorf("Failed to decode futures sell-cancel request")
+			WriteJsonError(w, http.StatusBadRequest, "failed to decode request body")
+			return
+		}
+
+		if err := adapter.CancelOrder(exchange.CurrencyPair{Base: request.Symbol}, request.OrderId); err != nil {
+			log.WithError(err).Errorf("Failed to cancel futures order %s", request.OrderId)
+			WriteJsonError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		WriteJsonResponse(w, http.StatusOK, map[string]interface{}{})
+	}
+}
+
+// openFuturesPosition fetches the open position for pair so
+// futuresMarketSellHandler and futuresLimitSellByPercentHandler can close
+// it by quantity/entry price instead of guessing at them. It returns the
+// first GetPositionRisk row with a nonzero amount; rows are not matched by
+// position side, so in hedge mode with both a LONG and a SHORT open it
+// returns whichever the exchange lists first.
+func openFuturesPosition(adapter *futures.Adapter, pair exchange.CurrencyPair) (futures.PositionRisk, bool, error) {
+	positions, err := adapter.GetPositionRisk(pair)
+	if err != nil {
+		return futures.PositionRisk{}, false, err
+	}
+	for _, p := range positions {
+		if amount, _ := strconv.ParseFloat(p.PositionAmt, 64); amount != 0 {
+			return p, true, nil
+		}
+	}
+	return futures.PositionRisk{}, false, nil
+}
+
+// futuresMarketSellHandler closes a trade's entire open futures position
+// at market, using PlaceCloseOrder's reduce-only order rather than the
+// generic PlaceOrder so it can only shrink the position, never flip it.
+func futuresMarketSellHandler(tradeService *tradeservice.TradeService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tradeId := mux.Vars(r)["tradeId"]
+
+		adapter, ok := futuresAdapter()
+		if !ok {
+			WriteJsonError(w, http.StatusNotFound, "binance-futures is not configured")
+			return
+		}
+
+		var request struct {
+			Symbol string `json:"symbol"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			log.WithError(err).Errorf("Failed to decode futures market-sell request")
+			WriteJsonError(w, http.StatusBadRequest, "failed to decode request body")
+			return
+		}
+
+		pair := exchange.CurrencyPair{Base: request.Symbol}
+		position, found, err := openFuturesPosition(adapter, pair)
+		if err != nil {
+			log.WithError(err).Errorf("Failed to load futures position for %s", request.Symbol)
+			WriteJsonError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if !found {
+			WriteJsonError(w, http.StatusNotFound, fmt.Sprintf("no open position for %s", request.Symbol))
+			return
+		}
+
+		positionAmount, _ := strconv.ParseFloat(position.PositionAmt, 64)
+		closeSide := exchange.OrderSideSell
+		if positionAmount < 0 {
+			closeSide = exchange.OrderSideBuy
+		}
+
+		order, err := adapter.PlaceCloseOrder(pair, closeSide, string(exchange.OrderTypeMarket), math.Abs(positionAmount), 0)
+		if err != nil {
+			log.WithError(err).Errorf("Failed to place futures market-sell order for %s", request.Symbol)
+			WriteJsonError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		if err := recordFuturesPosition(tradeService, tradeId, adapter, pair, closingPositionSide(closeSide)); err != nil {
+			log.WithError(err).Errorf("Failed to record futures position for trade %s", tradeId)
+		}
+
+		WriteJsonResponse(w, http.StatusOK, order)
+	}
+}
+
+// futuresLimitSellByPercentHandler closes a trade's entire open futures
+// position with a reduce-only LIMIT order percent away from its entry
+// price, the futures equivalent of limitSellByPercentHandler for spot.
+func futuresLimitSellByPercentHandler(tradeService *tradeservice.TradeService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tradeId := mux.Vars(r)["tradeId"]
+
+		adapter, ok := futuresAdapter()
+		if !ok {
+			WriteJsonError(w, http.StatusNotFound, "binance-futures is not configured")
+			return
+		}
+
+		var request struct {
+			Symbol  string  `json:"symbol"`
+			Percent float64 `json:"percent"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			log.WithError(err).Errorf("Failed to decode futures limit-sell-by-percent request")
+			WriteJsonError(w, http.StatusBadRequest, "failed to decode request body")
+			return
+		}
+
+		pair := exchange.CurrencyPair{Base: request.Symbol}
+		position, found, err := openFuturesPosition(adapter, pair)
+		if err != nil {
+			log.WithError(err).Errorf("Failed to load futures position for %s", request.Symbol)
+			WriteJsonError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if !found {
+			WriteJsonError(w, http.StatusNotFound, fmt.Sprintf("no open position for %s", request.Symbol))
+			return
+		}
+
+		positionAmount, _ := strconv.ParseFloat(position.PositionAmt, 64)
+		entryPrice, _ := strconv.ParseFloat(position.EntryPrice, 64)
+
+		closeSide := exchange.OrderSideSell
+		limitPrice := entryPrice * (1 + request.Percent/100)
+		if positionAmount < 0 {
+			closeSide = exchange.OrderSideBuy
+			limitPrice = entryPrice * (1 - request.Percent/100)
+		}
+
+		order, err := adapter.PlaceCloseOrder(pair, closeSide, string(exchange.OrderTypeLimit), math.Abs(positionAmount), limitPrice)
+		if err != nil {
+			log.WithError(err).Errorf("Failed to place futures limit-sell-by-percent order for %s", request.Symbol)
+			WriteJsonError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		if err := recordFuturesPosition(tradeService, tradeId, adapter, pair, closingPositionSide(closeSide)); err != nil {
+			log.WithError(err).Errorf("Failed to record futures position for trade %s", tradeId)
+		}
+
+		WriteJsonResponse(w, http.StatusOK, order)
+	}
+}
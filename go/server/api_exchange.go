@@ -0,0 +1,82 @@
+// Copyright (C) 2018 Cranky Kernel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package server
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"gitlab.com/crankykernel/maker/go/exchange"
+	"gitlab.com/crankykernel/maker/go/log"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"net/http"
+)
+
+// SaveExchangeConfigHandler saves API credentials for the exchange named by
+// the {exchange} path variable into the "exchanges" section of maker.yaml,
+// leaving the file's other sections as they were, then replaces that
+// exchange's adapter in exchangeRegistry so the new credentials take effect
+// immediately without a restart.
+func SaveExchangeConfigHandler(configFilename string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["exchange"]
+
+		var credentials map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&credentials); err != nil {
+			log.WithError(err).Errorf("Failed to decode %s config", name)
+			WriteJsonError(w, http.StatusBadRequest, "failed to decode request body")
+			return
+		}
+
+		config := struct {
+			Exchanges map[string]map[string]string `yaml:"exchanges"`
+		}{}
+		full := map[string]interface{}{}
+		if buf, err := ioutil.ReadFile(configFilename); err == nil {
+			if err := yaml.Unmarshal(buf, &config); err != nil {
+				log.WithError(err).Errorf("Failed to parse %s", configFilename)
+			}
+			yaml.Unmarshal(buf, &full)
+		}
+		if config.Exchanges == nil {
+			config.Exchanges = map[string]map[string]string{}
+		}
+		config.Exchanges[name] = credentials
+		full["exchanges"] = config.Exchanges
+
+		out, err := yaml.Marshal(full)
+		if err != nil {
+			log.WithError(err).Errorf("Failed to encode exchange configuration")
+			WriteJsonError(w, http.StatusInternalServerError, "failed to encode configuration")
+			return
+		}
+		if err := ioutil.WriteFile(configFilename, out, 0600); err != nil {
+			log.WithError(err).Errorf("Failed to write %s", configFilename)
+			WriteJsonError(w, http.StatusInternalServerError, "failed to save configuration")
+			return
+		}
+
+		adapter, err := exchange.New(name, credentials)
+		if err != nil {
+			log.WithError(err).Errorf("Failed to initialize %s exchange adapter", name)
+			WriteJsonError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		setExchangeAdapter(name, adapter)
+
+		WriteJsonResponse(w, http.StatusOK, map[string]interface{}{})
+	}
+}
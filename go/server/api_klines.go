@@ -0,0 +1,91 @@
+// Copyright (C) 2018 Cranky Kernel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package server
+
+import (
+	"gitlab.com/crankykernel/maker/go/binanceex"
+	"gitlab.com/crankykernel/maker/go/exchange"
+	"gitlab.com/crankykernel/maker/go/klinecache"
+	"gitlab.com/crankykernel/maker/go/log"
+	"net/http"
+	"strconv"
+)
+
+// binanceKlineLimit is the maximum number of klines Binance will return in
+// a single request; longer ranges must be chunked across multiple calls.
+const binanceKlineLimit = 1000
+
+// KlinesHandler serves /api/binance/klines?symbol=...&interval=...&startTime=...&endTime=...&limit=...&cursor=...
+//
+// It fetches the requested range from Binance in binanceKlineLimit-row
+// chunks on every request, saving each chunk to klinecache, then answers
+// from the cache. Ranges that are already cached are fetched again; the
+// cache does not spare repeated requests a trip to the exchange. cursor is
+// the openTime of the last row already seen by the caller, for simple
+// forward pagination.
+func KlinesHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	symbol := query.Get("symbol")
+	interval := query.Get("interval")
+	if symbol == "" || interval == "" {
+		WriteJsonError(w, http.StatusBadRequest, "symbol and interval are required")
+		return
+	}
+	startTime, _ := strconv.ParseInt(query.Get("startTime"), 10, 64)
+	endTime, _ := strconv.ParseInt(query.Get("endTime"), 10, 64)
+	cursor, _ := strconv.ParseInt(query.Get("cursor"), 10, 64)
+	limit, _ := strconv.Atoi(query.Get("limit"))
+
+	adapter, ok := getExchangeAdapter(binanceex.ExchangeName)
+	if ok {
+		if err := backfillKlines(adapter, symbol, interval, startTime, endTime); err != nil {
+			log.WithError(err).Errorf("Failed to backfill klines for %s %s", symbol, interval)
+		}
+	}
+
+	klines, err := klinecache.Query(binanceex.ExchangeName, symbol, interval, startTime, endTime, cursor, limit)
+	if err != nil {
+		log.WithError(err).Errorf("Failed to query kline cache")
+		WriteJsonError(w, http.StatusInternalServerError, "failed to query klines")
+		return
+	}
+
+	WriteJsonResponse(w, http.StatusOK, klines)
+}
+
+// backfillKlines fetches [startTime, endTime) from the exchange in chunks
+// of at most binanceKlineLimit rows and caches each chunk as it arrives.
+func backfillKlines(adapter exchange.Exchange, symbol, interval string, startTime, endTime int64) error {
+	pair := exchange.CurrencyPair{Base: symbol}
+	cursor := startTime
+	for {
+		klines, err := adapter.GetKlines(pair, interval, cursor, endTime, binanceKlineLimit)
+		if err != nil {
+			return err
+		}
+		if len(klines) == 0 {
+			return nil
+		}
+		if err := klinecache.Save(binanceex.ExchangeName, symbol, interval, klines); err != nil {
+			return err
+		}
+		last := klines[len(klines)-1]
+		if len(klines) < binanceKlineLimit || last.CloseTime >= endTime {
+			return nil
+		}
+		cursor = last.CloseTime + 1
+	}
+}
@@ -0,0 +1,30 @@
+// Copyright (C) 2018 Cranky Kernel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package server
+
+import (
+	"gitlab.com/crankykernel/maker/go/timesync"
+	"net/http"
+)
+
+// TimeProvidersHandler serves /api/time/providers, the per-provider
+// round-trip time, offset and health behind the single drift warning
+// ClientNoticeService broadcasts.
+func TimeProvidersHandler(timeSyncService *timesync.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		WriteJsonResponse(w, http.StatusOK, timeSyncService.Statuses())
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright (C) 2018 Cranky Kernel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"gitlab.com/crankykernel/maker/go/backtest"
+	"gitlab.com/crankykernel/maker/go/klinecache"
+	"gitlab.com/crankykernel/maker/go/log"
+	"gitlab.com/crankykernel/maker/go/tradeservice"
+	"io/ioutil"
+	"net/http"
+)
+
+// backtestInterval and backtestLookback bound how much cached history
+// backtestStopLoss and backtestTrailingProfit consult: a day of 5-minute
+// candles is enough to catch a setting that's already stale without
+// pulling a full chart's worth of klines for every confirmation.
+const backtestInterval = "5m"
+const backtestLookback = 288
+
+// backtestStopLoss wraps a stop-loss confirmation handler, rejecting a
+// proposed stop price that the last day of cached klines shows would
+// already have triggered, unless the request sets force to confirm it
+// anyway. It peeks at the request body without consuming it so the
+// wrapped handler still sees the original request.
+func backtestStopLoss(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Symbol    string  `json:"symbol"`
+			StopPrice float64 `json:"stopPrice"`
+			Force     bool    `json:"force"`
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			log.WithError(err).Errorf("Failed to read stop-loss request body")
+			WriteJsonError(w, http.StatusBadRequest, "failed to read request body")
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		if err := json.Unmarshal(body, &request); err == nil && request.Symbol != "" && !request.Force {
+			exchangeName := mux.Vars(r)["exchange"]
+			klines, err := klinecache.QueryRecent(exchangeName, request.Symbol, backtestInterval, backtestLookback)
+			if err != nil {
+				log.WithError(err).Errorf("Failed to load klines to backtest stop-loss for %s", request.Symbol)
+			} else if result := backtest.CheckStopLoss(klines, request.StopPrice); result.WouldHaveTriggered {
+				WriteJsonError(w, http.StatusConflict,
+					"stop price would have already triggered against recent klines; resend with force to confirm")
+				return
+			}
+		}
+
+		next(w, r)
+	}
+}
+
+// backtestTrailingProfit wraps a trailing-profit confirmation handler the
+// same way backtestStopLoss does, using the trade's actual entry price
+// (via tradeService) as the baseline instead of approximating it from the
+// lookback window, which would misfire whenever the window didn't happen
+// to start near where the trade actually opened.
+func backtestTrailingProfit(tradeService *tradeservice.TradeService, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Symbol  string  `json:"symbol"`
+			Percent float64 `json:"percent"`
+			Force   bool    `json:"force"`
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			log.WithError(err).Errorf("Failed to read trailing-profit request body")
+			WriteJsonError(w, http.StatusBadRequest, "failed to read request body")
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		if err := json.Unmarshal(body, &request); err == nil && request.Symbol != "" && !request.Force {
+			tradeId := mux.Vars(r)["tradeId"]
+			trade, err := tradeService.GetTrade(tradeId)
+			if err != nil {
+				log.WithError(err).Errorf("Failed to load trade %s to backtest trailing-profit", tradeId)
+			} else {
+				exchangeName := mux.Vars(r)["exchange"]
+				klines, err := klinecache.QueryRecent(exchangeName, request.Symbol, backtestInterval, backtestLookback)
+				if err != nil {
+					log.WithError(err).Errorf("Failed to load klines to backtest trailing-profit for %s", request.Symbol)
+				} else if result := backtest.CheckTrailingProfit(klines, trade.EntryPrice, request.Percent); result.WouldHaveTriggered {
+					WriteJsonError(w, http.StatusConflict,
+						"trailing-profit percent would have already triggered against recent klines; resend with force to confirm")
+					return
+				}
+			}
+		}
+
+		next(w, r)
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright (C) 2018 Cranky Kernel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package server
+
+import (
+	"encoding/json"
+	"gitlab.com/crankykernel/maker/go/log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// AggTrade is a normalized Binance aggregated trade, as returned by
+// TradesHandler.
+type AggTrade struct {
+	TradeId  int64   `json:"tradeId"`
+	Price    float64 `json:"price"`
+	Quantity float64 `json:"quantity"`
+	Time     int64   `json:"time"`
+	IsBuyer  bool    `json:"isBuyerMaker"`
+}
+
+// TradesHandler serves /api/binance/trades?symbol=..., proxying and
+// normalizing Binance's aggregated trades endpoint so the UI can plot
+// recent prints alongside candles without embedding Binance's field names.
+func TradesHandler(w http.ResponseWriter, r *http.Request) {
+	symbol := r.URL.Query().Get("symbol")
+	if symbol == "" {
+		WriteJsonError(w, http.StatusBadRequest, "symbol is required")
+		return
+	}
+
+	client := http.Client{Timeout: 10 * time.Second}
+	response, err := client.Get("https://api.binance.com/api/v3/aggTrades?symbol=" + symbol)
+	if err != nil {
+		log.WithError(err).Errorf("Failed to fetch trades for %s", symbol)
+		WriteJsonError(w, http.StatusBadGateway, "failed to fetch trades")
+		return
+	}
+	defer response.Body.Close()
+
+	var raw []struct {
+		AggTradeId   int64   `json:"a"`
+		Price        string  `json:"p"`
+		Quantity     string  `json:"q"`
+		Time         int64   `json:"T"`
+		IsBuyerMaker bool    `json:"m"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&raw); err != nil {
+		log.WithError(err).Errorf("Failed to decode trades response for %s", symbol)
+		WriteJsonError(w, http.StatusBadGateway, "failed to decode trades response")
+		return
+	}
+
+	trades := make([]AggTrade, 0, len(raw))
+	for _, t := range raw {
+		trades = append(trades, AggTrade{
+			TradeId:  t.AggTradeId,
+			Price:    parseFloatOrZero(t.Price),
+			Quantity: parseFloatOrZero(t.Quantity),
+			Time:     t.Time,
+			IsBuyer:  t.IsBuyerMaker,
+		})
+	}
+
+	WriteJsonResponse(w, http.StatusOK, trades)
+}
+
+func parseFloatOrZero(s string) float64 {
+	f, _ := strconv.ParseFloat(s, 64)
+	return f
+}
@@ -0,0 +1,109 @@
+// Copyright (C) 2018 Cranky Kernel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package server
+
+import (
+	"gitlab.com/crankykernel/maker/go/binanceex"
+	"gitlab.com/crankykernel/maker/go/log"
+	"gitlab.com/crankykernel/maker/go/tradeservice"
+	"math/rand"
+	"time"
+)
+
+// startChaosMonkey periodically forces one of the given supervisors to
+// drop its connection at a random interval between 1 and 6 minutes, so an
+// operator running with --chaos-ws can watch StreamSupervisor reconnect
+// and resync in a real environment rather than only in tests.
+func startChaosMonkey(supervisors []*binanceex.StreamSupervisor) {
+	log.Warnf("chaos-ws enabled: streams will be force-disconnected at random intervals")
+	go func() {
+		for {
+			delay := time.Duration(60+rand.Intn(300)) * time.Second
+			time.Sleep(delay)
+			if len(supervisors) == 0 {
+				continue
+			}
+			target := supervisors[rand.Intn(len(supervisors))]
+			target.Chaos()
+		}
+	}()
+}
+
+// legacyUserDataSession adapts the existing BinanceUserDataStream to
+// binanceex.Session so it can be run under a StreamSupervisor. It is the
+// stream's only subscriber; execution reports reach tradeService solely
+// through the supervisor's Events() channel. Because BinanceUserDataStream.Run
+// doesn't expose a way to tear down its connection, ForceDisconnect only
+// drops the relay this session hands to the supervisor; the underlying
+// stream keeps running and its single subscription is relayed again on the
+// next Connect, which still exercises the supervisor's reconnect and
+// resync path.
+type legacyUserDataSession struct {
+	stream          *binanceex.BinanceUserDataStream
+	tradeService    *tradeservice.TradeService
+	forceDisconnect chan struct{}
+	raw             <-chan binanceex.UserDataEvent
+}
+
+func newLegacyUserDataSession(stream *binanceex.BinanceUserDataStream, tradeService *tradeservice.TradeService) *legacyUserDataSession {
+	return &legacyUserDataSession{stream: stream, tradeService: tradeService}
+}
+
+func (s *legacyUserDataSession) Connect() (<-chan binanceex.StreamEvent, error) {
+	if s.raw == nil {
+		s.raw = s.stream.Subscribe()
+	}
+	raw := s.raw
+	relay := make(chan binanceex.StreamEvent)
+	forceDisconnect := make(chan struct{})
+	s.forceDisconnect = forceDisconnect
+
+	go func() {
+		defer close(relay)
+		for {
+			select {
+			case event, ok := <-raw:
+				if !ok {
+					return
+				}
+				relay <- binanceex.StreamEvent{EventTime: event.EventTime, Raw: event}
+			case <-forceDisconnect:
+				return
+			}
+		}
+	}()
+
+	return relay, nil
+}
+
+func (s *legacyUserDataSession) ForceDisconnect() {
+	if s.forceDisconnect != nil {
+		close(s.forceDisconnect)
+	}
+}
+
+func (s *legacyUserDataSession) KeepAlive() error {
+	// BinanceUserDataStream already manages its own listenKey keepalive
+	// internally; nothing to do here.
+	return nil
+}
+
+// Resync re-fetches open orders and account balances through tradeService
+// so any fill that happened on Binance while the stream was disconnected
+// is picked up instead of only being caught by the next execution report.
+func (s *legacyUserDataSession) Resync() error {
+	return s.tradeService.ResyncBinanceState()
+}
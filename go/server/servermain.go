@@ -22,13 +22,21 @@ import (
 	"github.com/gorilla/mux"
 	_ "github.com/mattn/go-sqlite3"
 	"gitlab.com/crankykernel/maker/go/binanceex"
+	_ "gitlab.com/crankykernel/maker/go/binanceex/futures"
+	_ "gitlab.com/crankykernel/maker/go/bitfinexex"
 	"gitlab.com/crankykernel/maker/go/context"
 	"gitlab.com/crankykernel/maker/go/db"
+	"gitlab.com/crankykernel/maker/go/exchange"
 	"gitlab.com/crankykernel/maker/go/gencert"
+	_ "gitlab.com/crankykernel/maker/go/huobiex"
+	"gitlab.com/crankykernel/maker/go/klinecache"
 	"gitlab.com/crankykernel/maker/go/log"
+	_ "gitlab.com/crankykernel/maker/go/okexex"
+	"gitlab.com/crankykernel/maker/go/timesync"
 	"gitlab.com/crankykernel/maker/go/tradeservice"
 	"gitlab.com/crankykernel/maker/go/version"
-	"math"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
 	"net/http"
 	"os"
 	"os/exec"
@@ -39,6 +47,97 @@ import (
 	"time"
 )
 
+// defaultExchange is used for routes and stored trades that do not specify
+// an exchange, so that configurations that only ever traded on Binance
+// continue to behave exactly as before.
+const defaultExchange = binanceex.ExchangeName
+
+// exchangeRegistry holds the configured adapter for every exchange this
+// server instance has credentials for. It is populated at startup from
+// maker.yaml and consulted by the /api/{exchange}/... routes. It is read
+// from request-handling goroutines and rewritten by SaveExchangeConfigHandler
+// in another, so all access must go through exchangeRegistryMu.
+var exchangeRegistry = map[string]exchange.Exchange{}
+var exchangeRegistryMu sync.RWMutex
+
+// getExchangeAdapter returns the configured adapter for name, if any.
+func getExchangeAdapter(name string) (exchange.Exchange, bool) {
+	exchangeRegistryMu.RLock()
+	defer exchangeRegistryMu.RUnlock()
+	adapter, ok := exchangeRegistry[name]
+	return adapter, ok
+}
+
+// setExchangeAdapter installs or replaces the configured adapter for name.
+func setExchangeAdapter(name string, adapter exchange.Exchange) {
+	exchangeRegistryMu.Lock()
+	defer exchangeRegistryMu.Unlock()
+	exchangeRegistry[name] = adapter
+}
+
+// loadExchangeRegistry builds an exchange.Exchange adapter for every
+// exchange with credentials configured in maker.yaml (under the
+// top-level "exchanges" key, keyed by exchange name). Binance is always
+// present, even without credentials, to preserve historical behaviour for
+// read-only endpoints like klines and trades.
+func loadExchangeRegistry(exchangeConfig map[string]map[string]string) map[string]exchange.Exchange {
+	registry := map[string]exchange.Exchange{}
+	for _, name := range exchange.Names() {
+		if name != binanceex.ExchangeName && len(exchangeConfig[name]) == 0 {
+			continue
+		}
+		adapter, err := exchange.New(name, exchangeConfig[name])
+		if err != nil {
+			log.WithError(err).Errorf("Failed to initialize %s exchange adapter", name)
+			continue
+		}
+		registry[name] = adapter
+	}
+	return registry
+}
+
+// readExchangeConfig reads the "exchanges" section of maker.yaml, mapping
+// exchange name to its credentials (eg. apiKey, apiSecret). A missing or
+// unreadable config file simply yields no configured exchanges other than
+// the always-present Binance default.
+func readExchangeConfig(filename string) map[string]map[string]string {
+	config := struct {
+		Exchanges map[string]map[string]string `yaml:"exchanges"`
+	}{}
+	buf, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return config.Exchanges
+	}
+	if err := yaml.Unmarshal(buf, &config); err != nil {
+		log.WithError(err).Errorf("Failed to parse exchange configuration from %s", filename)
+	}
+	return config.Exchanges
+}
+
+// requireBinanceExchange validates the {exchange} path variable against
+// exchangeRegistry and additionally requires it to be Binance:
+// PostBuyHandler and the other trade-lifecycle handlers it guards still
+// drive tradeService's BinanceTradeStreamManager directly rather than
+// dispatching through the matching exchange.Exchange adapter, so routing
+// a Huobi/OKEX/Bitfinex trade through them would silently place the
+// order on Binance instead. Reject it instead of doing that until those
+// handlers dispatch per-exchange.
+func requireBinanceExchange(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["exchange"]
+		if _, ok := getExchangeAdapter(name); !ok {
+			WriteJsonError(w, http.StatusNotFound, fmt.Sprintf("unknown exchange %q", name))
+			return
+		}
+		if name != binanceex.ExchangeName {
+			WriteJsonError(w, http.StatusNotImplemented,
+				fmt.Sprintf("trading on %q is not yet implemented; only %q is supported on this route", name, binanceex.ExchangeName))
+			return
+		}
+		next(w, r)
+	}
+}
+
 var ServerFlags struct {
 	Host           string
 	Port           int16
@@ -49,6 +148,19 @@ var ServerFlags struct {
 	TLS            bool
 	ItsAllMyFault  bool
 	EnableAuth     bool
+	ChaosWS        bool
+
+	// TimeSyncInterval is how often each registered timesync.Provider is
+	// checked against the system clock. Zero means one minute, the
+	// interval the old single-provider Binance check used.
+	TimeSyncInterval time.Duration
+
+	// TimeSyncThresholdMs is how far, in milliseconds, a quorum of
+	// providers must disagree with the system clock before
+	// ClientNoticeService warns about it. Defaults to 999ms, the
+	// threshold the old single-provider Binance check used, to keep
+	// current behavior.
+	TimeSyncThresholdMs int64
 }
 
 func initBinanceExchangeInfoService() *binanceex.ExchangeInfoService {
@@ -97,6 +209,10 @@ func ServerMain() {
 		}
 	}
 
+	if ServerFlags.ChaosWS && ServerFlags.Host != "127.0.0.1" {
+		log.Fatalf("--chaos-ws may only be used when listening on 127.0.0.1")
+	}
+
 	if ServerFlags.TLS {
 		pemFilename := fmt.Sprintf("%s/maker.pem", ServerFlags.DataDirectory)
 		if _, err := os.Stat(pemFilename); err != nil {
@@ -113,6 +229,14 @@ func ServerMain() {
 
 	db.DbOpen(ServerFlags.DataDirectory)
 
+	if err := klinecache.Open(ServerFlags.DataDirectory); err != nil {
+		log.WithError(err).Fatalf("Failed to open kline cache")
+	}
+
+	exchangeRegistryMu.Lock()
+	exchangeRegistry = loadExchangeRegistry(readExchangeConfig(ServerFlags.ConfigFilename))
+	exchangeRegistryMu.Unlock()
+
 	tradeService := tradeservice.NewTradeService(applicationContext.BinanceTradeStreamManager)
 	applicationContext.TradeService = tradeService
 
@@ -122,53 +246,75 @@ func ServerMain() {
 	binancePriceService := binanceex.NewBinancePriceService(binanceExchangeInfoService)
 
 	applicationContext.BinanceUserDataStream = binanceex.NewBinanceUserDataStream()
-	userStreamChannel := applicationContext.BinanceUserDataStream.Subscribe()
 	go applicationContext.BinanceUserDataStream.Run()
 
 	clientNoticeService := NewClientNoticeService()
 
-	go func() {
-		for {
-			client := binanceapi.NewRestClient()
-			requestStart := time.Now()
-			response, err := client.GetTime()
-			if err != nil {
-				log.WithError(err).Errorf("Failed to get from Binance API")
-				time.Sleep(1 * time.Minute)
-				continue
-			}
+	if adapter, ok := futuresAdapter(); ok {
+		go startFuturesLiquidationMonitor(adapter, func(level ClientNoticeLevel, message string) {
+			clientNoticeService.Broadcast(NewClientNotice(level, message))
+		})
+	}
 
-			roundTripTime := time.Now().Sub(requestStart)
-			now := time.Now().UnixNano() / int64(time.Millisecond)
-			diff := math.Abs(float64(now - response.ServerTime))
-			if diff > 999 {
-				log.WithFields(log.Fields{
-					"roundTripTime":          roundTripTime,
-					"binanceTimeDifferentMs": diff,
-				}).Warnf("Time difference from Binance servers may be too large; order may fail")
-				clientNoticeService.Broadcast(NewClientNotice(ClientNoticeLevelWarning,
-					"Time difference between Binance and Maker server too large, orders may fail."))
-			} else {
-				log.WithFields(log.Fields{
-					"roundTripTime":           roundTripTime,
-					"binanceTimeDifferenceMs": diff,
-				}).Infof("Binance time check")
-			}
-			time.Sleep(1 * time.Minute)
-		}
-	}()
+	// userDataSupervisor is the only subscriber to BinanceUserDataStream:
+	// it watches for reconnects, gaps, and (under --chaos-ws) simulated
+	// drops, resyncs open orders/balances whenever it reconnects, and
+	// forwards every event on Events() below. Subscribing a second time
+	// here for the execution-report loop would give BinanceUserDataStream
+	// two publish targets, and a slow reader on either one stalls both.
+	userDataSupervisor := binanceex.NewStreamSupervisor("binance-user-data",
+		newLegacyUserDataSession(applicationContext.BinanceUserDataStream, tradeService),
+		func(name, message string) {
+			clientNoticeService.Broadcast(NewClientNotice(ClientNoticeLevelInfo,
+				fmt.Sprintf("%s: %s", name, message)))
+		})
+	go userDataSupervisor.Run()
+
+	if ServerFlags.ChaosWS {
+		startChaosMonkey([]*binanceex.StreamSupervisor{userDataSupervisor})
+	}
+
+	// timeSyncService replaces the old hard-coded Binance-only GetTime
+	// loop: it cross-checks the system clock against every registered
+	// provider on its own schedule and only warns once a quorum of them
+	// disagree, so a single flaky provider (eg. a regional proxy to one
+	// exchange) no longer nags users who are otherwise fine to trade.
+	timeSyncInterval := ServerFlags.TimeSyncInterval
+	if timeSyncInterval == 0 {
+		timeSyncInterval = 1 * time.Minute
+	}
+	timeSyncThresholdMs := ServerFlags.TimeSyncThresholdMs
+	if timeSyncThresholdMs == 0 {
+		timeSyncThresholdMs = 999
+	}
+	timeSyncService := timesync.NewService(timeSyncInterval,
+		time.Duration(timeSyncThresholdMs)*time.Millisecond)
+	timeSyncService.AddProvider(timesync.BinanceSpotProvider{})
+	timeSyncService.AddProvider(timesync.BinanceFuturesProvider{})
+	timeSyncService.AddProvider(timesync.NTPProvider{Addr: "pool.ntp.org:123"})
+	timeSyncService.AddProvider(timesync.SystemClockProvider{})
+	timeSyncService.OnDrift = func(quorumOffsetMs int64) {
+		log.WithFields(log.Fields{
+			"quorumOffsetMs": quorumOffsetMs,
+		}).Warnf("Time difference from a quorum of time providers may be too large; orders may fail")
+		clientNoticeService.Broadcast(NewClientNotice(ClientNoticeLevelWarning,
+			"Time difference between a quorum of time providers and the Maker server too large, orders may fail."))
+	}
+	go timeSyncService.Run()
 
 	go func() {
-		for {
-			select {
-			case event := <-userStreamChannel:
-				switch event.EventType {
-				case binanceex.EventTypeExecutionReport:
-					if err := db.DbSaveBinanceRawExecutionReport(event.EventTime, event.Raw); err != nil {
-						log.Println(err)
-					}
-					tradeService.OnExecutionReport(event)
+		for streamEvent := range userDataSupervisor.Events() {
+			event, ok := streamEvent.Raw.(binanceex.UserDataEvent)
+			if !ok {
+				log.Errorf("binance-user-data: unexpected event type %T from supervisor", streamEvent.Raw)
+				continue
+			}
+			switch event.EventType {
+			case binanceex.EventTypeExecutionReport:
+				if err := db.DbSaveBinanceRawExecutionReport(event.EventTime, event.Raw); err != nil {
+					log.Println(err)
 				}
+				tradeService.OnExecutionReport(event)
 			}
 		}
 	}()
@@ -184,6 +330,7 @@ func ServerMain() {
 	router.HandleFunc("/api/config", configHandler).Methods("GET")
 	router.HandleFunc("/api/version", VersionHandler).Methods("GET")
 	router.HandleFunc("/api/time", TimeHandler).Methods("GET")
+	router.HandleFunc("/api/time/providers", TimeProvidersHandler(timeSyncService)).Methods("GET")
 	router.HandleFunc("/api/login", func(w http.ResponseWriter, r *http.Request) {
 		type LoginForm struct {
 			Username string `json:"username"`
@@ -213,41 +360,93 @@ func ServerMain() {
 		})
 	})
 
-	router.HandleFunc("/api/binance/buy", PostBuyHandler(tradeService, binancePriceService)).Methods("POST")
-	router.HandleFunc("/api/binance/buy", deleteBuyHandler(tradeService)).Methods("DELETE")
-	router.HandleFunc("/api/binance/sell", DeleteSellHandler(tradeService)).Methods("DELETE")
-
-	// Set/change stop-loss on a trade.
-	router.HandleFunc("/api/binance/trade/{tradeId}/stopLoss",
-		updateTradeStopLossSettingsHandler(tradeService)).Methods("POST")
-
-	router.HandleFunc("/api/binance/trade/{tradeId}/trailingProfit",
-		updateTradeTrailingProfitSettingsHandler(tradeService)).Methods("POST")
+	// binance-futures trading is native to the exchange end to end (market
+	// orders, conditional orders, leverage) rather than emulated
+	// client-side like spot's, so it gets its own handlers for the full
+	// trade lifecycle, registered ahead of the generic {exchange} routes
+	// below so they take precedence for this exchange instead of falling
+	// through and executing as a Binance spot order.
+	router.HandleFunc("/api/binance-futures/buy",
+		futuresBuyHandler(tradeService, ServerFlags.ConfigFilename)).Methods("POST")
+	router.HandleFunc("/api/binance-futures/buy",
+		futuresCancelBuyHandler()).Methods("DELETE")
+	router.HandleFunc("/api/binance-futures/sell",
+		futuresSellHandler()).Methods("DELETE")
+	router.HandleFunc("/api/binance-futures/trade/{tradeId}/stopLoss",
+		futuresStopLossHandler(tradeService)).Methods("POST")
+	router.HandleFunc("/api/binance-futures/trade/{tradeId}/trailingProfit",
+		futuresTrailingProfitHandler(tradeService)).Methods("POST")
+	router.HandleFunc("/api/binance-futures/trade/{tradeId}/limitSellByPercent",
+		futuresLimitSellByPercentHandler(tradeService)).Methods("POST")
+	router.HandleFunc("/api/binance-futures/trade/{tradeId}/marketSell",
+		futuresMarketSellHandler(tradeService)).Methods("POST")
+	// Archiving and abandoning a trade are bookkeeping on the trade record
+	// itself, not an exchange action, so the generic handlers apply to
+	// futures trades unchanged.
+	router.HandleFunc("/api/binance-futures/trade/{tradeId}/archive",
+		archiveTradeHandler(tradeService)).Methods("POST")
+	router.HandleFunc("/api/binance-futures/trade/{tradeId}/abandon",
+		abandonTradeHandler(tradeService)).Methods("POST")
+	router.HandleFunc("/api/binance-futures/preferences/leverage/{symbol}",
+		futuresLeveragePreferenceHandler(ServerFlags.ConfigFilename)).Methods("POST")
+
+	// Routes are keyed by exchange name (eg. /api/binance/buy,
+	// /api/huobi/buy) so the URL shape is exchange-agnostic, but the
+	// handlers behind them are not yet: they drive tradeService's
+	// BinanceTradeStreamManager directly rather than dispatching through
+	// exchangeRegistry, so requireBinanceExchange rejects every exchange
+	// but Binance here rather than silently executing the order against
+	// it. Huobi/OKEX/Bitfinex adapters are reachable today via their
+	// read-only/config routes; wiring them into order placement is
+	// tracked separately.
+	router.HandleFunc("/api/{exchange}/buy", requireBinanceExchange(PostBuyHandler(tradeService, binancePriceService))).Methods("POST")
+	router.HandleFunc("/api/{exchange}/buy", requireBinanceExchange(deleteBuyHandler(tradeService))).Methods("DELETE")
+	router.HandleFunc("/api/{exchange}/sell", requireBinanceExchange(DeleteSellHandler(tradeService))).Methods("DELETE")
+
+	// Set/change stop-loss on a trade. backtestStopLoss rejects a setting
+	// that recent cached klines show would already have triggered before
+	// it ever reaches the trade, unless the caller forces it through.
+	router.HandleFunc("/api/{exchange}/trade/{tradeId}/stopLoss",
+		requireBinanceExchange(backtestStopLoss(updateTradeStopLossSettingsHandler(tradeService)))).Methods("POST")
+
+	router.HandleFunc("/api/{exchange}/trade/{tradeId}/trailingProfit",
+		requireBinanceExchange(backtestTrailingProfit(tradeService, updateTradeTrailingProfitSettingsHandler(tradeService)))).Methods("POST")
 
 	// Limit sell at percent.
-	router.HandleFunc("/api/binance/trade/{tradeId}/limitSellByPercent",
-		limitSellByPercentHandler(tradeService)).Methods("POST")
+	router.HandleFunc("/api/{exchange}/trade/{tradeId}/limitSellByPercent",
+		requireBinanceExchange(limitSellByPercentHandler(tradeService))).Methods("POST")
 
 	// Limit sell at price.
-	router.HandleFunc("/api/binance/trade/{tradeId}/limitSellByPrice",
-		limitSellByPriceHandler(tradeService)).Methods("POST")
+	router.HandleFunc("/api/{exchange}/trade/{tradeId}/limitSellByPrice",
+		requireBinanceExchange(limitSellByPriceHandler(tradeService))).Methods("POST")
 
-	router.HandleFunc("/api/binance/trade/{tradeId}/marketSell",
-		marketSellHandler(tradeService)).Methods("POST")
-	router.HandleFunc("/api/binance/trade/{tradeId}/archive",
-		archiveTradeHandler(tradeService)).Methods("POST")
-	router.HandleFunc("/api/binance/trade/{tradeId}/abandon",
-		abandonTradeHandler(tradeService)).Methods("POST")
+	router.HandleFunc("/api/{exchange}/trade/{tradeId}/marketSell",
+		requireBinanceExchange(marketSellHandler(tradeService))).Methods("POST")
+	router.HandleFunc("/api/{exchange}/trade/{tradeId}/archive",
+		requireBinanceExchange(archiveTradeHandler(tradeService))).Methods("POST")
+	router.HandleFunc("/api/{exchange}/trade/{tradeId}/abandon",
+		requireBinanceExchange(abandonTradeHandler(tradeService))).Methods("POST")
 
 	router.HandleFunc("/api/trade/query", queryTradesHandler).
 		Methods("GET")
 	router.HandleFunc("/api/trade/{tradeId}",
 		getTradeHandler).Methods("GET")
 
+	router.HandleFunc("/api/binance/klines", KlinesHandler).Methods("GET")
+	router.HandleFunc("/api/binance/trades", TradesHandler).Methods("GET")
+
 	router.HandleFunc("/api/binance/account/test",
 		BinanceTestHandler).Methods("GET")
 	router.HandleFunc("/api/binance/config",
 		SaveBinanceConfigHandler).Methods("POST")
+
+	// Generic per-exchange credential storage, replacing the Binance-only
+	// SaveBinanceConfigHandler for every exchange except Binance, which
+	// keeps its dedicated route above for backwards compatibility with
+	// existing maker.yaml files and UI clients.
+	router.HandleFunc("/api/{exchange}/config",
+		SaveExchangeConfigHandler(ServerFlags.ConfigFilename)).Methods("POST")
+
 	router.HandleFunc("/api/config/preferences",
 		SavePreferencesHandler).Methods("POST")
 
@@ -0,0 +1,138 @@
+// Copyright (C) 2018 Cranky Kernel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package timesync
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/crankykernel/binanceapi-go"
+	"gitlab.com/crankykernel/maker/go/binanceex/futures"
+)
+
+// BinanceSpotProvider checks time against Binance's spot API, the same
+// endpoint ServerMain used to poll exclusively before timesync.Service
+// existed.
+type BinanceSpotProvider struct{}
+
+func (BinanceSpotProvider) Name() string {
+	return "binance-spot"
+}
+
+func (BinanceSpotProvider) Check() (time.Time, time.Duration, error) {
+	client := binanceapi.NewRestClient()
+	requestStart := time.Now()
+	response, err := client.GetTime()
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	roundTripTime := time.Now().Sub(requestStart)
+	return time.Unix(0, response.ServerTime*int64(time.Millisecond)), roundTripTime, nil
+}
+
+// BinanceFuturesProvider checks time against Binance's USDⓈ-M futures API,
+// which runs on separate infrastructure from spot and so can drift
+// independently of it.
+type BinanceFuturesProvider struct{}
+
+func (BinanceFuturesProvider) Name() string {
+	return "binance-futures"
+}
+
+func (BinanceFuturesProvider) Check() (time.Time, time.Duration, error) {
+	client := futures.NewRestClient("", "")
+	requestStart := time.Now()
+	serverTime, err := client.GetServerTime()
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	roundTripTime := time.Now().Sub(requestStart)
+	return time.Unix(0, serverTime*int64(time.Millisecond)), roundTripTime, nil
+}
+
+// NTPProvider checks time against an NTP pool, for users who want a
+// reference independent of Binance entirely.
+type NTPProvider struct {
+	// Addr is the NTP server to query, eg. "pool.ntp.org:123".
+	Addr string
+}
+
+func (p NTPProvider) Name() string {
+	return "ntp:" + p.Addr
+}
+
+// ntpEpochOffset is the number of seconds between the NTP epoch
+// (1900-01-01) and the Unix epoch (1970-01-01).
+const ntpEpochOffset = 2208988800
+
+func (p NTPProvider) Check() (time.Time, time.Duration, error) {
+	conn, err := net.DialTimeout("udp", p.Addr, 5*time.Second)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	request := make([]byte, 48)
+	request[0] = 0x1B // LI = 0, VN = 3, Mode = 3 (client)
+
+	requestStart := time.Now()
+	if _, err := conn.Write(request); err != nil {
+		return time.Time{}, 0, err
+	}
+
+	response := make([]byte, 48)
+	if _, err := conn.Read(response); err != nil {
+		return time.Time{}, 0, err
+	}
+	roundTripTime := time.Now().Sub(requestStart)
+
+	// The transmit timestamp is a 64-bit fixed-point value starting at
+	// byte 40: seconds since the NTP epoch, then a fractional part.
+	seconds := binary.BigEndian.Uint32(response[40:44])
+	fraction := binary.BigEndian.Uint32(response[44:48])
+	if seconds == 0 {
+		return time.Time{}, 0, fmt.Errorf("timesync: empty NTP response from %s", p.Addr)
+	}
+	nanos := (int64(fraction) * int64(time.Second)) >> 32
+	serverTime := time.Unix(int64(seconds)-ntpEpochOffset, nanos)
+
+	return serverTime, roundTripTime, nil
+}
+
+// SystemClockProvider always reports the system clock itself, so that it
+// always appears in /api/time/providers as the baseline every other
+// provider is compared against rather than only being implicit.
+type SystemClockProvider struct{}
+
+func (SystemClockProvider) Name() string {
+	return "system"
+}
+
+func (SystemClockProvider) Check() (time.Time, time.Duration, error) {
+	return time.Now(), 0, nil
+}
+
+// Baseline marks SystemClockProvider as the reference point checkAll
+// compares every other provider against rather than a vote of its own: it
+// always reports the system clock, so it can never disagree with itself
+// and would otherwise make real drift harder to detect by inflating the
+// number of respondents a quorum is measured against.
+func (SystemClockProvider) Baseline() bool {
+	return true
+}
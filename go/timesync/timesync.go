@@ -0,0 +1,167 @@
+// Copyright (C) 2018 Cranky Kernel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package timesync cross-checks the local clock against multiple time
+// providers (Binance spot, Binance futures, an NTP pool, ...) instead of
+// polling a single exchange's GetTime endpoint, so a warning about clock
+// drift only fires when a quorum of providers agrees something is wrong
+// rather than on one flaky provider's say-so.
+package timesync
+
+import (
+	"sync"
+	"time"
+)
+
+// Provider is a source of server time that can be compared against the
+// local clock.
+type Provider interface {
+	Name() string
+
+	// Check returns the provider's current time and the round-trip time
+	// the request took.
+	Check() (serverTime time.Time, roundTripTime time.Duration, err error)
+}
+
+// baselineProvider is implemented by a Provider that is the reference
+// point the others are compared against rather than an independent
+// opinion of the correct time — SystemClockProvider, whose Check always
+// returns the system clock itself, so it trivially agrees with itself on
+// every check. checkAll still records its status for /api/time/providers
+// but excludes it from the quorum count below, since counting it as a
+// respondent that never disagrees silently raises the bar for every real
+// provider to reach quorum.
+type baselineProvider interface {
+	Baseline() bool
+}
+
+// ProviderStatus is the last observed state of a Provider, returned by
+// /api/time/providers.
+type ProviderStatus struct {
+	Name          string        `json:"name"`
+	Healthy       bool          `json:"healthy"`
+	LastError     string        `json:"lastError,omitempty"`
+	LastCheckedAt time.Time     `json:"lastCheckedAt"`
+	RoundTripTimeMs int64       `json:"roundTripTimeMs"`
+	OffsetMs      int64         `json:"offsetMs"`
+}
+
+// Service periodically checks every registered Provider against the
+// system clock and raises a warning only when a quorum of providers
+// disagree with it by more than Threshold.
+type Service struct {
+	Interval  time.Duration
+	Threshold time.Duration
+
+	// OnDrift is called whenever a quorum of providers disagree with the
+	// system clock by more than Threshold. quorumOffsetMs is the offset
+	// of the last provider checked that disagreed.
+	OnDrift func(quorumOffsetMs int64)
+
+	lock      sync.RWMutex
+	providers []Provider
+	status    map[string]ProviderStatus
+}
+
+func NewService(interval, threshold time.Duration) *Service {
+	return &Service{
+		Interval:  interval,
+		Threshold: threshold,
+		status:    map[string]ProviderStatus{},
+	}
+}
+
+// AddProvider registers a time provider to be checked on every interval.
+func (s *Service) AddProvider(provider Provider) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.providers = append(s.providers, provider)
+}
+
+// Statuses returns the last-seen status of every registered provider, for
+// /api/time/providers.
+func (s *Service) Statuses() []ProviderStatus {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	statuses := make([]ProviderStatus, 0, len(s.providers))
+	for _, provider := range s.providers {
+		statuses = append(statuses, s.status[provider.Name()])
+	}
+	return statuses
+}
+
+// Run checks every provider on Interval forever. It should be run in its
+// own goroutine and does not return.
+func (s *Service) Run() {
+	for {
+		s.checkAll()
+		time.Sleep(s.Interval)
+	}
+}
+
+func (s *Service) checkAll() {
+	s.lock.RLock()
+	providers := append([]Provider{}, s.providers...)
+	s.lock.RUnlock()
+
+	disagreeing := 0
+	responded := 0
+	var quorumOffsetMs int64
+
+	for _, provider := range providers {
+		status := ProviderStatus{Name: provider.Name(), LastCheckedAt: time.Now()}
+
+		requestStart := time.Now()
+		serverTime, roundTripTime, err := provider.Check()
+		if err != nil {
+			status.Healthy = false
+			status.LastError = err.Error()
+			s.setStatus(status)
+			continue
+		}
+
+		now := requestStart.Add(roundTripTime / 2)
+		offset := now.Sub(serverTime)
+
+		status.Healthy = true
+		status.RoundTripTimeMs = roundTripTime.Milliseconds()
+		status.OffsetMs = offset.Milliseconds()
+		s.setStatus(status)
+
+		if baseline, ok := provider.(baselineProvider); ok && baseline.Baseline() {
+			continue
+		}
+
+		responded++
+		if offset > s.Threshold || offset < -s.Threshold {
+			disagreeing++
+			quorumOffsetMs = status.OffsetMs
+		}
+	}
+
+	// A quorum is a strict majority of providers that actually responded;
+	// with zero or one respondents there is no quorum to reach.
+	if responded > 1 && disagreeing*2 > responded {
+		if s.OnDrift != nil {
+			s.OnDrift(quorumOffsetMs)
+		}
+	}
+}
+
+func (s *Service) setStatus(status ProviderStatus) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.status[status.Name] = status
+}